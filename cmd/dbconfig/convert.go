@@ -0,0 +1,86 @@
+/*
+ * © 2023 fkmatsuda
+
+ * This file is licensed under the terms of the MIT license. Permission is hereby
+ * granted, free of charge, to any person obtaining a copy of this software and
+ * associated documentation files (the "Software"), to deal in the Software without
+ * restriction, including without limitation the rights to use, copy, modify,
+ * merge, publish, and/or distribute copies of the Software, and to permit persons
+ * to whom the Software is furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS," WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY,
+ * WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+ * CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ *
+ */
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/fkmatsuda-dev/dbconfig"
+)
+
+func runConvert(args []string, stdout io.Writer, stderr io.Writer) int {
+	flags := flag.NewFlagSet("dbconfig convert", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: dbconfig convert [flags] <source>")
+		fmt.Fprintln(stderr, "\nThe source is env, a database URL, a .env file or a json, yaml or toml configuration file.")
+		flags.PrintDefaults()
+	}
+	to := flags.String("to", "", "output format: json, yaml, toml, env, url or shell, defaults to the format of the "+
+		"-output extension or json")
+	output := flags.String("output", "", "file written with the 0600 mode instead of the standard output")
+	if err := flags.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return exitOK
+		}
+		return exitUsage
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return exitUsage
+	}
+
+	format := *to
+	if format == "" {
+		format = "json"
+		if *output != "" {
+			var err error
+			if format, err = dbconfig.ConfigFileFormat(*output); err != nil {
+				printError(stderr, err)
+				return exitUsage
+			}
+		}
+	}
+	content, err := dbconfig.Convert(flags.Arg(0), format)
+	if err != nil {
+		printError(stderr, err)
+		return exitFailure
+	}
+	if *output == "" {
+		fmt.Fprint(stdout, string(content))
+		return exitOK
+	}
+	err = os.WriteFile(*output, content, 0600)
+	if err == nil {
+		// the mode of an existing file is not changed by os.WriteFile
+		err = os.Chmod(*output, 0600)
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "dbconfig convert: %s\n", err.Error())
+		return exitFailure
+	}
+	return exitOK
+}
@@ -0,0 +1,86 @@
+/*
+ * © 2023 fkmatsuda
+
+ * This file is licensed under the terms of the MIT license. Permission is hereby
+ * granted, free of charge, to any person obtaining a copy of this software and
+ * associated documentation files (the "Software"), to deal in the Software without
+ * restriction, including without limitation the rights to use, copy, modify,
+ * merge, publish, and/or distribute copies of the Software, and to permit persons
+ * to whom the Software is furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS," WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY,
+ * WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+ * CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ *
+ */
+
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"text/tabwriter"
+
+	"github.com/fkmatsuda-dev/dbconfig"
+)
+
+// runDiff compares two configurations, like diff it exits with 0 when they are equal, 1 when they are different and
+// 2 when they cannot be compared
+func runDiff(args []string, stdout io.Writer, stderr io.Writer) int {
+	flags := flag.NewFlagSet("dbconfig diff", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: dbconfig diff [flags] <old source> <new source>")
+		fmt.Fprintln(stderr, "\nThe sources are env, database URLs, .env files or json, yaml or toml configuration files.")
+		flags.PrintDefaults()
+	}
+	format := flags.String("format", "table", "output format: table or json")
+	if err := flags.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return exitOK
+		}
+		return exitUsage
+	}
+	if flags.NArg() != 2 || (*format != "table" && *format != "json") {
+		flags.Usage()
+		return exitUsage
+	}
+
+	changes, err := dbconfig.DiffSources(flags.Arg(0), flags.Arg(1))
+	if err != nil {
+		printError(stderr, err)
+		return exitUsage
+	}
+	if *format == "json" {
+		if changes == nil {
+			changes = []dbconfig.FieldChange{}
+		}
+		content, _ := json.MarshalIndent(changes, "", "  ")
+		fmt.Fprintln(stdout, string(content))
+	} else if len(changes) == 0 {
+		fmt.Fprintln(stdout, "no changes")
+	} else {
+		table := tabwriter.NewWriter(stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(table, "FIELD\tOLD\tNEW\tRECONNECT")
+		for _, change := range changes {
+			reconnect := "no"
+			if change.Reconnect {
+				reconnect = "yes"
+			}
+			fmt.Fprintf(table, "%s\t%s\t%s\t%s\n", change.Field, change.Old, change.New, reconnect)
+		}
+		table.Flush()
+	}
+	if len(changes) > 0 {
+		return exitFailure
+	}
+	return exitOK
+}
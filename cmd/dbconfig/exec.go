@@ -0,0 +1,170 @@
+/*
+ * © 2023 fkmatsuda
+
+ * This file is licensed under the terms of the MIT license. Permission is hereby
+ * granted, free of charge, to any person obtaining a copy of this software and
+ * associated documentation files (the "Software"), to deal in the Software without
+ * restriction, including without limitation the rights to use, copy, modify,
+ * merge, publish, and/or distribute copies of the Software, and to permit persons
+ * to whom the Software is furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS," WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY,
+ * WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+ * CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ *
+ */
+
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"github.com/fkmatsuda-dev/dbconfig"
+)
+
+// exitNotStarted is the exit code when the command cannot be started, like in the shells
+const exitNotStarted = 127
+
+// clientEnv are the inherited environment variables removed from the environment of the child process, so they
+// cannot override the loaded configuration
+var clientEnv = []string{
+	"PGHOST", "PGHOSTADDR", "PGPORT", "PGDATABASE", "PGUSER", "PGPASSWORD", "PGPASSFILE", "PGSERVICE",
+	"PGSERVICEFILE", "PGSSLMODE", "PGSSLROOTCERT", "PGSSLCERT", "PGSSLKEY",
+	"MYSQL_HOST", "MYSQL_TCP_PORT", "MYSQL_PWD",
+}
+
+func runExec(args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) int {
+	flags, src := newFlagSet("exec", stderr)
+	if err := flags.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return exitOK
+		}
+		return exitUsage
+	}
+	if flags.NArg() == 0 {
+		fmt.Fprintln(stderr, "dbconfig exec: missing command, usage: dbconfig exec [flags] -- <command> [args]")
+		return exitUsage
+	}
+	config, err := src.load()
+	if err != nil {
+		printError(stderr, err)
+		return exitFailure
+	}
+	cmd, cleanup, err := clientCommand(config, flags.Args())
+	if err != nil {
+		printError(stderr, err)
+		return exitFailure
+	}
+	defer cleanup()
+	cmd.Stdin = stdin
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	return runChild(cmd, stderr)
+}
+
+// clientCommand returns the command with the credentials of the configuration, the PostgreSQL family clients
+// receive the libpq environment variables and the MySQL family clients a temporary option file in the
+// --defaults-extra-file first argument, the cleanup function removes the temporary files
+func clientCommand(config dbconfig.Config, command []string) (*exec.Cmd, func(), error) {
+	environ := inheritedEnv()
+	args := command[1:]
+	cleanup := func() {}
+	switch config.Type.Family() {
+	case dbconfig.DbTypeMysql:
+		file, err := dbconfig.WriteTempMysqlOptionFile(config)
+		if err != nil {
+			return nil, nil, err
+		}
+		cleanup = func() {
+			_ = os.Remove(file)
+		}
+		args = append([]string{"--defaults-extra-file=" + file}, args...)
+	case dbconfig.DbTypePostgres:
+		environ = append(environ, config.LibpqEnv()...)
+	default:
+		return nil, nil, fmt.Errorf("dbconfig exec: %s configurations are not supported", config.Type)
+	}
+	cmd := exec.Command(command[0], args...)
+	cmd.Env = environ
+	return cmd, cleanup, nil
+}
+
+// inheritedEnv returns the environment of the process without the client connection variables
+func inheritedEnv() []string {
+	var environ []string
+	for _, variable := range os.Environ() {
+		name, _, _ := strings.Cut(variable, "=")
+		if !contains(clientEnv, name) {
+			environ = append(environ, variable)
+		}
+	}
+	return environ
+}
+
+// runChild runs the command forwarding the interrupt and termination signals to it and returns its exit code, or 128
+// plus the signal number when a signal killed it
+func runChild(cmd *exec.Cmd, stderr io.Writer) int {
+	// the signals are forwarded so the temporary files are removed after the child exits
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(signals)
+
+	if err := cmd.Start(); err != nil {
+		fmt.Fprintf(stderr, "dbconfig exec: %s\n", err.Error())
+		return exitNotStarted
+	}
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		for {
+			select {
+			case sig := <-signals:
+				_ = cmd.Process.Signal(sig)
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	err := cmd.Wait()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		if code := exitErr.ExitCode(); code >= 0 {
+			return code
+		}
+		// like the shells, a child killed by a signal exits with 128 plus the signal number
+		if status, ok := exitErr.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+			return 128 + int(status.Signal())
+		}
+		return exitFailure
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "dbconfig exec: %s\n", err.Error())
+		return exitFailure
+	}
+	return exitOK
+}
+
+// contains reports whether the value is in the list
+func contains(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
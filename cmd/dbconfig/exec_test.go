@@ -0,0 +1,197 @@
+/*
+ * © 2023 fkmatsuda
+
+ * This file is licensed under the terms of the MIT license. Permission is hereby
+ * granted, free of charge, to any person obtaining a copy of this software and
+ * associated documentation files (the "Software"), to deal in the Software without
+ * restriction, including without limitation the rights to use, copy, modify,
+ * merge, publish, and/or distribute copies of the Software, and to permit persons
+ * to whom the Software is furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS," WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY,
+ * WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+ * CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ *
+ */
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/fkmatsuda-dev/commons/files"
+)
+
+// fakeClient prints its arguments, the client environment variables and the content of the option file, and exits
+// with the DBCONFIG_FAKE_EXIT code, or is killed by SIGTERM when DBCONFIG_FAKE_SIGNAL is set
+func fakeClient() int {
+	for _, arg := range os.Args[1:] {
+		fmt.Printf("arg %s\n", arg)
+		if file, ok := strings.CutPrefix(arg, "--defaults-extra-file="); ok {
+			content, err := os.ReadFile(file)
+			if err != nil {
+				return 99
+			}
+			fmt.Printf("file %s\n", strings.ReplaceAll(string(content), "\n", " "))
+		}
+	}
+	for _, variable := range os.Environ() {
+		if strings.HasPrefix(variable, "PG") || strings.HasPrefix(variable, "MYSQL_") {
+			fmt.Printf("env %s\n", variable)
+		}
+	}
+	if os.Getenv("DBCONFIG_FAKE_SIGNAL") != "" {
+		process, _ := os.FindProcess(os.Getpid())
+		_ = process.Signal(syscall.SIGTERM)
+		time.Sleep(10 * time.Second)
+	}
+	code, _ := strconv.Atoi(os.Getenv("DBCONFIG_FAKE_EXIT"))
+	return code
+}
+
+func TestExec(t *testing.T) {
+
+	// Create a temporary directory inside system temp directory
+	dirName, err := files.CreateTempDir()
+	if err != nil {
+		t.Errorf("Error creating temporary directory: %s", err.Error())
+		return
+	}
+	defer func() {
+		_ = files.CleanupTempDirs()
+	}()
+
+	client, err := os.Executable()
+	if err != nil {
+		t.Errorf("Error finding the test executable: %s", err.Error())
+		return
+	}
+
+	// writeConfig writes the configuration file and returns its name
+	writeConfig := func(name string, content string) string {
+		configFile := dirName + "/" + name
+		if err := os.WriteFile(configFile, []byte(content), 0600); err != nil {
+			t.Errorf("Error writing config file: %s", err.Error())
+		}
+		return configFile
+	}
+
+	// runExecCommand runs the exec command with the fake client and returns the exit code and the outputs
+	runExecCommand := func(configFile string, args ...string) (int, string, string) {
+		var stdout, stderr bytes.Buffer
+		code := run(append([]string{"exec", "-file", configFile, "--", client}, args...), nil, &stdout, &stderr)
+		return code, stdout.String(), stderr.String()
+	}
+
+	// Test exec PostgreSQL client
+	t.Run("Test exec PostgreSQL client", func(t *testing.T) {
+		t.Setenv("DBCONFIG_FAKE_CLIENT", "1")
+		t.Setenv("PGSERVICE", "inherited")
+		configFile := writeConfig("postgres.json", `{"type": "POSTGRESQL", "host": "db.example.com", "port": 5432,
+			"user": "app", "password": "s3cr3t", "database": "orders",
+			"ssl": {"mode": "verify-ca", "ca": "/etc/ssl/ca.crt"}}`)
+
+		code, stdout, stderr := runExecCommand(configFile, "-c", "select 1")
+		if code != exitOK {
+			t.Errorf("The exec command must exit with 0, got %d: %s", code, stderr)
+			return
+		}
+		for _, line := range []string{
+			"arg -c", "arg select 1", "env PGHOST=db.example.com", "env PGPORT=5432", "env PGUSER=app",
+			"env PGPASSWORD=s3cr3t", "env PGDATABASE=orders", "env PGSSLMODE=verify-ca",
+			"env PGSSLROOTCERT=/etc/ssl/ca.crt",
+		} {
+			if !strings.Contains(stdout, line+"\n") {
+				t.Errorf("The output must contain \"%s\", got \"%s\"", line, stdout)
+			}
+		}
+		if strings.Contains(stdout, "PGSERVICE") {
+			t.Errorf("The inherited PGSERVICE variable must be removed")
+		}
+		for _, line := range strings.Split(stdout, "\n") {
+			if strings.HasPrefix(line, "arg ") && strings.Contains(line, "s3cr3t") {
+				t.Errorf("The password must not be passed as an argument")
+			}
+		}
+	})
+
+	// Test exec MySQL client
+	t.Run("Test exec MySQL client", func(t *testing.T) {
+		t.Setenv("DBCONFIG_FAKE_CLIENT", "1")
+		t.Setenv("DBCONFIG_FAKE_EXIT", "3")
+		configFile := writeConfig("mysql.json", `{"type": "MYSQL", "host": "db.example.com", "port": 3306,
+			"user": "app", "password": "s3cr3t", "database": "orders"}`)
+
+		code, stdout, stderr := runExecCommand(configFile, "-e", "select 1")
+		if code != 3 {
+			t.Errorf("The exit code of the client must be passed through, got %d: %s", code, stderr)
+			return
+		}
+		lines := strings.Split(stdout, "\n")
+		optionFile, ok := strings.CutPrefix(lines[0], "arg --defaults-extra-file=")
+		if !ok {
+			t.Errorf("The option file must be the first argument, got \"%s\"", lines[0])
+			return
+		}
+		if !strings.Contains(stdout, `password="s3cr3t"`) || !strings.Contains(stdout, `database="orders"`) {
+			t.Errorf("The option file must contain the credentials, got \"%s\"", stdout)
+			return
+		}
+		for _, line := range lines {
+			if strings.HasPrefix(line, "arg ") && strings.Contains(line, "s3cr3t") {
+				t.Errorf("The password must not be passed as an argument")
+			}
+		}
+		if _, err := os.Stat(optionFile); !os.IsNotExist(err) {
+			t.Errorf("The option file must be removed after the client exits")
+			return
+		}
+	})
+
+	// Test exec client killed by a signal
+	t.Run("Test exec client killed by a signal", func(t *testing.T) {
+		if runtime.GOOS == "windows" {
+			t.Skip("The processes are not killed by signals on windows")
+		}
+		t.Setenv("DBCONFIG_FAKE_CLIENT", "1")
+		t.Setenv("DBCONFIG_FAKE_SIGNAL", "1")
+		configFile := writeConfig("signal.json", `{"type": "POSTGRESQL", "host": "localhost", "port": 5432,
+			"user": "app", "password": "s3cr3t", "database": "orders"}`)
+
+		code, _, stderr := runExecCommand(configFile)
+		if expected := 128 + int(syscall.SIGTERM); code != expected {
+			t.Errorf("The exit code %d expected, got %d: %s", expected, code, stderr)
+			return
+		}
+	})
+
+	// Test exec errors
+	t.Run("Test exec errors", func(t *testing.T) {
+		configFile := writeConfig("exec-errors.json", `{"type": "POSTGRESQL", "host": "localhost", "port": 5432,
+			"user": "app", "password": "s3cr3t", "database": "orders"}`)
+
+		var stdout, stderr bytes.Buffer
+		if code := run([]string{"exec", "-file", configFile}, nil, &stdout, &stderr); code != exitUsage {
+			t.Errorf("A missing command must exit with 2, got %d", code)
+		}
+		missing := dirName + "/missing-client"
+		if code := run([]string{"exec", "-file", configFile, "--", missing}, nil, &stdout, &stderr); code != exitNotStarted {
+			t.Errorf("A missing client must exit with 127, got %d", code)
+		}
+	})
+
+}
@@ -0,0 +1,822 @@
+/*
+ * © 2023 fkmatsuda
+
+ * This file is licensed under the terms of the MIT license. Permission is hereby
+ * granted, free of charge, to any person obtaining a copy of this software and
+ * associated documentation files (the "Software"), to deal in the Software without
+ * restriction, including without limitation the rights to use, copy, modify,
+ * merge, publish, and/or distribute copies of the Software, and to permit persons
+ * to whom the Software is furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS," WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY,
+ * WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+ * CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ *
+ */
+
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/fkmatsuda-dev/commons/errorex"
+	"github.com/fkmatsuda-dev/dbconfig"
+	"golang.org/x/term"
+)
+
+// initSettings are the settings of the init command, they are set by the flags and completed by the prompts
+type initSettings struct {
+	dbType          string
+	host            string
+	port            string
+	user            string
+	password        string
+	passwordCommand string
+	database        string
+	sslMode         string
+	sslCa           string
+	sslCert         string
+	sslKey          string
+	// sqlserverInstance is the SQL Server named instance, empty for the default instance
+	sqlserverInstance string
+	// the Oracle connect target, one of the service name, SID or TNS alias
+	oracleServiceName string
+	oracleSID         string
+	oracleTNSAlias    string
+	oracleTNSAdmin    string
+	// the YugabyteDB load balancing options
+	yugabyteLoadBalance  string
+	yugabyteTopologyKeys string
+	// the MongoDB seed list and authentication options
+	mongodbSRV           string
+	mongodbHosts         string
+	mongodbReplicaSet    string
+	mongodbAuthSource    string
+	mongodbAuthMechanism string
+	// the Redis deployment mode, the sentinel master and the other sentinel or cluster addresses
+	redisMode       string
+	redisMasterName string
+	redisAddrs      string
+	// the ClickHouse protocol, compression and other hosts
+	clickhouseProtocol    string
+	clickhouseCompression string
+	clickhouseHosts       string
+	// the SQLite options, empty to keep the SQLite defaults
+	sqliteJournalMode string
+	sqliteBusyTimeout string
+	sqliteForeignKeys string
+}
+
+// initField is a setting asked by the init command
+type initField struct {
+	label string
+	value *string
+	// secret fields are read without echo from the terminals
+	secret bool
+	// defaultValue returns the default value of the field when it is not set
+	defaultValue func() string
+	// skip returns true when the field is not needed by the other settings
+	skip func() bool
+	// check validates the value of the field
+	check func(value string) error
+}
+
+// errInputEnded is returned when the input ends before all the settings are read
+var errInputEnded = errors.New("dbconfig init: the input ended before all the settings were read")
+
+func runInit(args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) int {
+	flags := flag.NewFlagSet("dbconfig init", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	settings := &initSettings{}
+	output := flags.String("output", "dbconfig.json", "file to write, the format is given by the extension: "+
+		".json, .yaml, .yml, .toml or .env")
+	force := flags.Bool("force", false, "overwrite the output file when it exists")
+	nonInteractive := flags.Bool("non-interactive", false, "do not ask for the settings, use the flags only")
+	flags.StringVar(&settings.dbType, "type", "", "database type: "+strings.Join(dbTypeNames(), ", "))
+	flags.StringVar(&settings.host, "host", "localhost", "database host")
+	flags.StringVar(&settings.port, "port", "", "database port, defaults to the port of the database type")
+	flags.StringVar(&settings.user, "user", "", "database user")
+	flags.StringVar(&settings.password, "password", "", "database password, visible to the other local users, "+
+		"prefer -password-command in scripts")
+	flags.StringVar(&settings.passwordCommand, "password-command", "", "command whose output is the password")
+	flags.StringVar(&settings.database, "database", "", "database name, the database file or :memory: for SQLite")
+	flags.StringVar(&settings.sslMode, "ssl-mode", "prefer", "ssl mode: disable, allow, prefer, require, verify-ca "+
+		"or verify-full")
+	flags.StringVar(&settings.sslCa, "ssl-ca", "",
+		"ssl root certificate file, used by the verify-ca and verify-full modes")
+	flags.StringVar(&settings.sslCert, "ssl-cert", "", "ssl client certificate file, used by the verify-full mode")
+	flags.StringVar(&settings.sslKey, "ssl-key", "", "ssl client key file, used by the verify-full mode")
+	flags.StringVar(&settings.sqlserverInstance, "sqlserver-instance", "", "SQL Server named instance, empty for the "+
+		"default instance")
+	flags.StringVar(&settings.oracleServiceName, "oracle-service-name", "", "Oracle service name")
+	flags.StringVar(&settings.oracleSID, "oracle-sid", "", "Oracle SID, instead of the service name")
+	flags.StringVar(&settings.oracleTNSAlias, "oracle-tns-alias", "", "Oracle TNS alias, instead of the service name "+
+		"and the host")
+	flags.StringVar(&settings.oracleTNSAdmin, "oracle-tns-admin", "", "directory of the tnsnames.ora file, "+
+		"defaults to $TNS_ADMIN")
+	flags.StringVar(&settings.yugabyteLoadBalance, "yugabyte-load-balance", "", "spread the YugabyteDB connections "+
+		"over the cluster servers: true or false")
+	flags.StringVar(&settings.yugabyteTopologyKeys, "yugabyte-topology-keys", "", "comma separated "+
+		"cloud.region.zone placements preferred by the YugabyteDB load balancing")
+	flags.StringVar(&settings.mongodbSRV, "mongodb-srv", "", "look up the MongoDB seed list in the DNS SRV records "+
+		"of the host, like mongodb+srv://: true or false")
+	flags.StringVar(&settings.mongodbHosts, "mongodb-hosts", "", "comma separated host:port list of the other "+
+		"MongoDB hosts")
+	flags.StringVar(&settings.mongodbReplicaSet, "mongodb-replica-set", "", "MongoDB replica set name")
+	flags.StringVar(&settings.mongodbAuthSource, "mongodb-auth-source", "", "MongoDB database of the user, "+
+		"defaults to the database")
+	flags.StringVar(&settings.mongodbAuthMechanism, "mongodb-auth-mechanism", "", "MongoDB authentication "+
+		"mechanism, like SCRAM-SHA-256 or MONGODB-X509")
+	flags.StringVar(&settings.redisMode, "redis-mode", "", "Redis mode: STANDALONE, SENTINEL or CLUSTER")
+	flags.StringVar(&settings.redisMasterName, "redis-master-name", "", "Redis master name of the SENTINEL mode")
+	flags.StringVar(&settings.redisAddrs, "redis-addrs", "", "comma separated host:port list of the other Redis "+
+		"sentinels or cluster nodes")
+	flags.StringVar(&settings.clickhouseProtocol, "clickhouse-protocol", "", "ClickHouse protocol: NATIVE or HTTP")
+	flags.StringVar(&settings.clickhouseCompression, "clickhouse-compression", "", "ClickHouse compression: none, "+
+		"lz4, zstd or, with the HTTP protocol, gzip, deflate or br")
+	flags.StringVar(&settings.clickhouseHosts, "clickhouse-hosts", "", "comma separated host:port list of the "+
+		"other ClickHouse hosts")
+	flags.StringVar(&settings.sqliteJournalMode, "sqlite-journal-mode", "", "SQLite journal mode: DELETE, TRUNCATE, "+
+		"PERSIST, MEMORY, WAL or OFF")
+	flags.StringVar(&settings.sqliteBusyTimeout, "sqlite-busy-timeout", "", "SQLite busy timeout in milliseconds")
+	flags.StringVar(&settings.sqliteForeignKeys, "sqlite-foreign-keys", "", "enable the SQLite foreign key "+
+		"constraints: true or false")
+	if code, ok := parseFlags(flags, args); !ok {
+		return code
+	}
+
+	// check the output before asking for the settings
+	if _, err := dbconfig.ConfigFileFormat(*output); err != nil {
+		printError(stderr, err)
+		return exitUsage
+	}
+	if _, err := os.Stat(*output); err == nil && !*force {
+		fmt.Fprintf(stderr, "dbconfig init: %s already exists, use -force to overwrite it\n", *output)
+		return exitFailure
+	}
+
+	var err error
+	if *nonInteractive {
+		err = settings.checkFields()
+	} else {
+		err = settings.askFields(newPrompter(stdin, stdout))
+	}
+	var config dbconfig.Config
+	if err == nil {
+		config, err = settings.config()
+	}
+	if err == nil {
+		err = dbconfig.WriteConfigFile(*output, config)
+	}
+	if err != nil {
+		printError(stderr, err)
+		return exitFailure
+	}
+	fmt.Fprintf(stdout, "%s written\n", *output)
+	return exitOK
+}
+
+// typeFields maps the database types to the function returning the settings asked after the type, the types
+// without their own settings use serverFields
+var typeFields = map[dbconfig.DbType]func(s *initSettings) []initField{
+	dbconfig.DbTypeSqlite:     (*initSettings).sqliteFields,
+	dbconfig.DbTypeSqlserver:  (*initSettings).sqlserverFields,
+	dbconfig.DbTypeOracle:     (*initSettings).oracleFields,
+	dbconfig.DbTypeYugabytedb: (*initSettings).yugabyteFields,
+	dbconfig.DbTypeMongodb:    (*initSettings).mongodbFields,
+	dbconfig.DbTypeRedis:      (*initSettings).redisFields,
+	dbconfig.DbTypeClickhouse: (*initSettings).clickhouseFields,
+}
+
+// typeField returns the database type setting, it is asked before the settings of the type
+func (s *initSettings) typeField() initField {
+	return initField{label: "Database type (" + strings.Join(dbTypeNames(), ", ") + ")", value: &s.dbType, skip: never,
+		check: func(value string) error {
+			_, err := dbconfig.ParseDbType(strings.ToUpper(value))
+			return err
+		}}
+}
+
+// fields returns the settings of the database type in the order they are asked
+func (s *initSettings) fields() []initField {
+	dbType, err := dbconfig.ParseDbType(strings.ToUpper(s.dbType))
+	if err != nil {
+		return nil
+	}
+	if fields, ok := typeFields[dbType]; ok {
+		return fields(s)
+	}
+	return s.serverFields()
+}
+
+// serverFields returns the settings of the database servers with the host, the credentials, the database and the
+// SSL files
+func (s *initSettings) serverFields() []initField {
+	fields := s.hostFields(never, never, s.defaultPort)
+	fields = append(fields, s.credentialFields(false, never)...)
+	fields = append(fields, s.databaseField(false))
+	return append(fields, s.sslFields(false, true, false)...)
+}
+
+// sqliteFields returns the settings of SQLite, it has no server and only needs the database file and its options
+func (s *initSettings) sqliteFields() []initField {
+	return []initField{
+		{label: "Database file (or " + dbconfig.SqliteMemory + ")", value: &s.database, skip: never,
+			check: required("database file")},
+		{label: "Journal mode (DELETE, TRUNCATE, PERSIST, MEMORY, WAL, OFF, empty for the default)",
+			value: &s.sqliteJournalMode, skip: never, check: func(value string) error {
+				if value == "" {
+					return nil
+				}
+				_, err := dbconfig.ParseSqliteJournalMode(value)
+				return err
+			}},
+		{label: "Busy timeout in milliseconds (empty for the default)", value: &s.sqliteBusyTimeout, skip: never,
+			check: func(value string) error {
+				if _, err := strconv.ParseUint(value, 10, 32); value != "" && err != nil {
+					return fmt.Errorf("\"%s\" is not a valid busy timeout", value)
+				}
+				return nil
+			}},
+		{label: "Enable foreign keys (true, false)", value: &s.sqliteForeignKeys, skip: never, check: checkBool},
+	}
+}
+
+// sqlserverFields returns the settings of SQL Server, it has the named instances and no client certificates
+func (s *initSettings) sqlserverFields() []initField {
+	fields := s.hostFields(never, never, s.defaultPort)
+	fields = append(fields, initField{label: "Instance name (empty for the default instance)",
+		value: &s.sqlserverInstance, skip: never, check: optional})
+	fields = append(fields, s.credentialFields(false, never)...)
+	fields = append(fields, s.databaseField(false))
+	return append(fields, s.sslFields(true, false, false)...)
+}
+
+// oracleFields returns the settings of Oracle, it connects to a service name, SID or TNS alias instead of the
+// database and only verifies the certificates with the system roots
+func (s *initSettings) oracleFields() []initField {
+	// the TNS alias gives the host and port
+	tnsAlias := func() bool { return s.oracleTNSAlias != "" }
+	fields := []initField{
+		{label: "Service name (empty to use a SID or TNS alias)", value: &s.oracleServiceName, skip: never,
+			check: optional},
+		{label: "SID (empty to use a TNS alias)", value: &s.oracleSID,
+			skip: func() bool { return s.oracleServiceName != "" }, check: optional},
+		{label: "TNS alias", value: &s.oracleTNSAlias,
+			skip:  func() bool { return s.oracleServiceName != "" || s.oracleSID != "" },
+			check: required("oracle service name, sid or tns alias")},
+		{label: "TNS_ADMIN directory (empty to use the TNS_ADMIN environment variable)", value: &s.oracleTNSAdmin,
+			skip: func() bool { return !tnsAlias() }, check: optional},
+	}
+	fields = append(fields, s.hostFields(tnsAlias, tnsAlias, s.defaultPort)...)
+	fields = append(fields, s.credentialFields(false, never)...)
+	return append(fields, s.sslModeField())
+}
+
+// yugabyteFields returns the settings of YugabyteDB, the PostgreSQL settings and the load balancing of the smart
+// drivers
+func (s *initSettings) yugabyteFields() []initField {
+	return append(s.serverFields(),
+		initField{label: "Load balance (true, false)", value: &s.yugabyteLoadBalance, skip: never, check: checkBool},
+		initField{label: "Topology keys (empty for any placement)", value: &s.yugabyteTopologyKeys, check: optional,
+			skip: func() bool {
+				loadBalance, _ := strconv.ParseBool(s.yugabyteLoadBalance)
+				return !loadBalance
+			}},
+	)
+}
+
+// mongodbFields returns the settings of MongoDB, it has the servers without authentication, the seed lists and the
+// certificates are verified with the system roots
+func (s *initSettings) mongodbFields() []initField {
+	srv := func() bool {
+		srv, _ := strconv.ParseBool(s.mongodbSRV)
+		return srv
+	}
+	anonymous := func() bool { return s.user == "" }
+	fields := []initField{{label: "Use the DNS seed list (mongodb+srv, true, false)", value: &s.mongodbSRV,
+		skip: never, check: checkBool}}
+	fields = append(fields, s.hostFields(never, srv, s.defaultPort)...)
+	fields = append(fields,
+		initField{label: "Other hosts (comma separated host:port, empty for a single host)", value: &s.mongodbHosts,
+			skip: srv, check: optional},
+		initField{label: "Replica set (empty for none)", value: &s.mongodbReplicaSet, skip: never, check: optional},
+		initField{label: "Auth mechanism (SCRAM-SHA-256, MONGODB-X509, MONGODB-AWS, ..., empty for the default)",
+			value: &s.mongodbAuthMechanism, skip: never, check: optional},
+	)
+	fields = append(fields, s.credentialFields(true, anonymous)...)
+	fields = append(fields,
+		s.databaseField(true),
+		initField{label: "Auth source (empty for the database)", value: &s.mongodbAuthSource, skip: anonymous,
+			check: optional},
+	)
+	return append(fields, s.sslFields(true, true, true)...)
+}
+
+// redisFields returns the settings of Redis, it has the servers without authentication, the sentinel and cluster
+// modes and a database index instead of the database
+func (s *initSettings) redisFields() []initField {
+	redisMode := func(mode dbconfig.RedisMode) bool { return strings.EqualFold(s.redisMode, mode.String()) }
+	fields := []initField{
+		{label: "Redis mode (STANDALONE, SENTINEL, CLUSTER)", value: &s.redisMode, skip: never,
+			check: func(value string) error {
+				_, err := dbconfig.ParseRedisMode(value)
+				return err
+			}, defaultValue: dbconfig.RedisModeStandalone.String},
+		{label: "Master name", value: &s.redisMasterName,
+			skip:  func() bool { return !redisMode(dbconfig.RedisModeSentinel) },
+			check: required("redis master name")},
+	}
+	fields = append(fields, s.hostFields(never, never, func() string {
+		if redisMode(dbconfig.RedisModeSentinel) {
+			return strconv.Itoa(int(dbconfig.RedisSentinelPort))
+		}
+		return s.defaultPort()
+	})...)
+	fields = append(fields, initField{label: "Other addresses (comma separated host:port, empty for none)",
+		value: &s.redisAddrs, check: optional, skip: func() bool {
+			return !redisMode(dbconfig.RedisModeSentinel) && !redisMode(dbconfig.RedisModeCluster)
+		}})
+	fields = append(fields, s.credentialFields(true, never)...)
+	fields = append(fields, initField{label: "Database index (empty for 0)", value: &s.database,
+		skip: func() bool { return redisMode(dbconfig.RedisModeCluster) },
+		check: func(value string) error {
+			if _, err := strconv.ParseUint(value, 10, 16); value != "" && err != nil {
+				return fmt.Errorf("\"%s\" is not a valid database index", value)
+			}
+			return nil
+		}})
+	return append(fields, s.sslFields(true, true, true)...)
+}
+
+// clickhouseFields returns the settings of ClickHouse, it connects with the default user and database when they are
+// not set and its default port depends on the protocol
+func (s *initSettings) clickhouseFields() []initField {
+	clickhouseProtocol := func() dbconfig.ClickhouseProtocol {
+		protocol, err := dbconfig.ParseClickhouseProtocol(s.clickhouseProtocol)
+		if err != nil {
+			return dbconfig.ClickhouseProtocolNative
+		}
+		return protocol
+	}
+	fields := []initField{{label: "ClickHouse protocol (NATIVE, HTTP)", value: &s.clickhouseProtocol, skip: never,
+		check: func(value string) error {
+			_, err := dbconfig.ParseClickhouseProtocol(value)
+			return err
+		}, defaultValue: dbconfig.ClickhouseProtocolNative.String}}
+	fields = append(fields, s.hostFields(never, never, func() string {
+		secure := s.sslMode == "require" || s.sslMode == "verify-ca" || s.sslMode == "verify-full"
+		return strconv.Itoa(int(dbconfig.ClickhouseDefaultPort(clickhouseProtocol(), secure)))
+	})...)
+	fields = append(fields,
+		initField{label: "Other hosts (comma separated host:port, empty for a single host)",
+			value: &s.clickhouseHosts, skip: never, check: optional},
+		initField{label: "Compression (" + strings.Join(dbconfig.ClickhouseCompression[dbconfig.ClickhouseProtocolHTTP],
+			", ") + ", empty for none)", value: &s.clickhouseCompression, skip: never,
+			check: func(value string) error {
+				for _, compression := range dbconfig.ClickhouseCompression[clickhouseProtocol()] {
+					if strings.EqualFold(value, compression) {
+						return nil
+					}
+				}
+				if value == "" {
+					return nil
+				}
+				return fmt.Errorf("\"%s\" compression is not supported by the %s protocol", value, clickhouseProtocol())
+			}},
+	)
+	fields = append(fields, s.credentialFields(true, never)...)
+	fields = append(fields, s.databaseField(true))
+	return append(fields, s.sslFields(true, true, true)...)
+}
+
+// hostFields returns the host and port settings, the port defaults to the defaultPort value
+func (s *initSettings) hostFields(skipHost func() bool, skipPort func() bool, defaultPort func() string) []initField {
+	return []initField{
+		{label: "Host", value: &s.host, skip: skipHost, check: required("host")},
+		{label: "Port", value: &s.port, skip: skipPort, check: checkPort, defaultValue: defaultPort},
+	}
+}
+
+// defaultPort returns the default port of the database type
+func (s *initSettings) defaultPort() string {
+	dbType, _ := dbconfig.ParseDbType(strings.ToUpper(s.dbType))
+	return strconv.Itoa(int(dbType.DefaultPort()))
+}
+
+// credentialFields returns the user, password and password command settings, the credentials are optional for the
+// anonymous servers and the passwords are not asked when skipPassword returns true
+func (s *initSettings) credentialFields(anonymous bool, skipPassword func() bool) []initField {
+	return []initField{
+		{label: "User", value: &s.user, skip: never, check: requiredBy("user", anonymous)},
+		{label: "Password (empty to use a password command)", value: &s.password, secret: true,
+			skip:  func() bool { return s.passwordCommand != "" || skipPassword() },
+			check: optional},
+		{label: "Password command", value: &s.passwordCommand,
+			skip:  func() bool { return s.password != "" || skipPassword() },
+			check: requiredBy("password or password command", anonymous)},
+	}
+}
+
+// databaseField returns the database setting, it is optional for the anonymous servers
+func (s *initSettings) databaseField(anonymous bool) initField {
+	return initField{label: "Database", value: &s.database, skip: never, check: requiredBy("database", anonymous)}
+}
+
+// sslModeField returns the SSL mode setting
+func (s *initSettings) sslModeField() initField {
+	return initField{label: "SSL mode (disable, allow, prefer, require, verify-ca, verify-full)", value: &s.sslMode,
+		skip: never, check: func(value string) error {
+			_, err := dbconfig.ParseSSLMode(value)
+			return err
+		}}
+}
+
+// sslFields returns the SSL mode and the certificate files of the verify modes, the root certificate is optional
+// when the server certificate can be verified with the system roots and the client certificates are only asked when
+// the type supports them
+func (s *initSettings) sslFields(systemRoots bool, clientCertificates bool, optionalCertificates bool) []initField {
+	verifyCA := func() bool { return s.sslMode != "verify-ca" && s.sslMode != "verify-full" }
+	verifyFull := func() bool { return s.sslMode != "verify-full" }
+	fields := []initField{
+		s.sslModeField(),
+		{label: "SSL root certificate file", value: &s.sslCa, skip: verifyCA, check: func(value string) error {
+			if value == "" && systemRoots {
+				return nil
+			}
+			return checkFile(value)
+		}},
+	}
+	if !clientCertificates {
+		return fields
+	}
+	optionalFiles := optionalFile(func() bool { return optionalCertificates })
+	return append(fields,
+		initField{label: "SSL client certificate file", value: &s.sslCert, skip: verifyFull, check: optionalFiles},
+		initField{label: "SSL client key file", value: &s.sslKey, skip: verifyFull, check: optionalFiles},
+	)
+}
+
+// checkFields checks the settings set by the flags
+func (s *initSettings) checkFields() error {
+	for _, field := range append([]initField{s.typeField()}, s.fields()...) {
+		if field.skip() {
+			continue
+		}
+		if *field.value == "" && field.defaultValue != nil {
+			*field.value = field.defaultValue()
+		}
+		if err := field.check(*field.value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// askFields asks for each setting until its value is valid, the values set by the flags are the defaults, the
+// settings of the database type are only known after the type is asked
+func (s *initSettings) askFields(p *prompter) error {
+	if err := s.askField(p, s.typeField()); err != nil {
+		return err
+	}
+	for _, field := range s.fields() {
+		if err := s.askField(p, field); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// askField asks for the setting until its value is valid
+func (s *initSettings) askField(p *prompter, field initField) error {
+	if field.skip() {
+		return nil
+	}
+	if *field.value == "" && field.defaultValue != nil {
+		*field.value = field.defaultValue()
+	}
+	if field.secret && *field.value != "" {
+		return nil
+	}
+	for {
+		value, err := p.ask(field.label, *field.value, field.secret)
+		if err != nil {
+			return err
+		}
+		if err := field.check(value); err != nil {
+			p.warn(err)
+			continue
+		}
+		*field.value = value
+		return nil
+	}
+}
+
+// config returns the configuration of the settings validated by Config.Validate
+func (s *initSettings) config() (dbconfig.Config, error) {
+	dbType, err := dbconfig.ParseDbType(strings.ToUpper(s.dbType))
+	if err != nil {
+		return dbconfig.Config{}, err
+	}
+	if dbType == dbconfig.DbTypeSqlite {
+		return s.sqliteConfig()
+	}
+	sslMode, err := dbconfig.ParseSSLMode(s.sslMode)
+	if err != nil {
+		return dbconfig.Config{}, err
+	}
+	port, _ := strconv.ParseUint(s.port, 10, 16)
+	config := dbconfig.Config{
+		Type:            dbType,
+		Host:            s.host,
+		Port:            uint16(port),
+		User:            s.user,
+		Password:        s.password,
+		PasswordCommand: s.passwordCommand,
+		Database:        s.database,
+		SSL:             &dbconfig.SSLConfig{Mode: sslMode},
+	}
+	if config.Password != "" {
+		config.PasswordCommand = ""
+	}
+	if sslMode == dbconfig.SSLModeVerifyCA || sslMode == dbconfig.SSLModeVerifyFull {
+		config.SSL.Ca = s.sslCa
+	}
+	if sslMode == dbconfig.SSLModeVerifyFull && dbType != dbconfig.DbTypeSqlserver {
+		config.SSL.Cert = s.sslCert
+		config.SSL.Key = s.sslKey
+	}
+	if dbType == dbconfig.DbTypeSqlserver && s.sqlserverInstance != "" {
+		config.Sqlserver = &dbconfig.SqlserverConfig{Instance: s.sqlserverInstance}
+	}
+	if loadBalance, _ := strconv.ParseBool(s.yugabyteLoadBalance); dbType == dbconfig.DbTypeYugabytedb && loadBalance {
+		config.Yugabyte = &dbconfig.YugabyteConfig{LoadBalance: true, TopologyKeys: s.yugabyteTopologyKeys}
+	}
+	if dbType == dbconfig.DbTypeMongodb {
+		config.Mongodb = s.mongodbConfig()
+		// the SRV records give the ports of the hosts
+		if config.Port == 0 {
+			config.Port = dbType.DefaultPort()
+		}
+	}
+	if dbType == dbconfig.DbTypeRedis {
+		redis, err := s.redisConfig()
+		if err != nil {
+			return dbconfig.Config{}, err
+		}
+		config.Redis = redis
+	}
+	if dbType == dbconfig.DbTypeClickhouse {
+		clickhouse, err := s.clickhouseConfig()
+		if err != nil {
+			return dbconfig.Config{}, err
+		}
+		config.Clickhouse = clickhouse
+	}
+	if dbType == dbconfig.DbTypeOracle {
+		config.Database = ""
+		config.SSL.Ca, config.SSL.Cert, config.SSL.Key = "", "", ""
+		config.Oracle = &dbconfig.OracleConfig{
+			ServiceName: s.oracleServiceName,
+			SID:         s.oracleSID,
+			TNSAlias:    s.oracleTNSAlias,
+			TNSAdmin:    s.oracleTNSAdmin,
+		}
+		if s.oracleTNSAlias != "" {
+			config.Host, config.Port = "", 0
+		}
+	}
+	return config, config.Validate()
+}
+
+// mongodbConfig returns the MongoDB options of the settings, nil when they are not set
+func (s *initSettings) mongodbConfig() *dbconfig.MongodbConfig {
+	options := dbconfig.MongodbConfig{
+		ReplicaSet:    s.mongodbReplicaSet,
+		AuthSource:    s.mongodbAuthSource,
+		AuthMechanism: s.mongodbAuthMechanism,
+	}
+	options.SRV, _ = strconv.ParseBool(s.mongodbSRV)
+	if !options.SRV {
+		options.Hosts = splitList(s.mongodbHosts)
+	}
+	if len(options.Hosts) == 0 && !options.SRV && options.ReplicaSet == "" && options.AuthSource == "" &&
+		options.AuthMechanism == "" {
+		return nil
+	}
+	return &options
+}
+
+// redisConfig returns the Redis options of the settings, nil for the standalone mode
+func (s *initSettings) redisConfig() (*dbconfig.RedisConfig, error) {
+	if s.redisMode == "" {
+		return nil, nil
+	}
+	mode, err := dbconfig.ParseRedisMode(s.redisMode)
+	if err != nil || mode == dbconfig.RedisModeStandalone {
+		return nil, err
+	}
+	options := dbconfig.RedisConfig{Mode: mode, Addrs: splitList(s.redisAddrs)}
+	if mode == dbconfig.RedisModeSentinel {
+		options.MasterName = s.redisMasterName
+	}
+	return &options, nil
+}
+
+// clickhouseConfig returns the ClickHouse options of the settings, nil for the native protocol without compression
+// and other hosts
+func (s *initSettings) clickhouseConfig() (*dbconfig.ClickhouseConfig, error) {
+	options := dbconfig.ClickhouseConfig{
+		Compression: strings.ToLower(s.clickhouseCompression),
+		Hosts:       splitList(s.clickhouseHosts),
+	}
+	if s.clickhouseProtocol != "" {
+		protocol, err := dbconfig.ParseClickhouseProtocol(s.clickhouseProtocol)
+		if err != nil {
+			return nil, err
+		}
+		// the native protocol is the default
+		if protocol == dbconfig.ClickhouseProtocolHTTP {
+			options.Protocol = protocol
+		}
+	}
+	if options.Protocol == 0 && options.Compression == "" && len(options.Hosts) == 0 {
+		return nil, nil
+	}
+	return &options, nil
+}
+
+// sqliteConfig returns the SQLite configuration of the settings validated by Config.Validate
+func (s *initSettings) sqliteConfig() (dbconfig.Config, error) {
+	config := dbconfig.Config{Type: dbconfig.DbTypeSqlite, Database: s.database}
+	options := dbconfig.SqliteConfig{}
+	if s.sqliteJournalMode != "" {
+		journalMode, err := dbconfig.ParseSqliteJournalMode(s.sqliteJournalMode)
+		if err != nil {
+			return dbconfig.Config{}, err
+		}
+		options.JournalMode = journalMode
+	}
+	busyTimeout, _ := strconv.ParseUint(s.sqliteBusyTimeout, 10, 32)
+	options.BusyTimeout = uint32(busyTimeout)
+	options.ForeignKeys, _ = strconv.ParseBool(s.sqliteForeignKeys)
+	if options != (dbconfig.SqliteConfig{}) {
+		config.Sqlite = &options
+	}
+	return config, config.Validate()
+}
+
+// dbTypeNames returns the sorted names of the database types
+func dbTypeNames() []string {
+	names := make([]string, 0, len(dbconfig.DbTypeValue))
+	for name := range dbconfig.DbTypeValue {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// splitList splits a comma separated list, the empty items are skipped
+func splitList(value string) []string {
+	var list []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+// never is the skip function of the settings that are always asked
+func never() bool {
+	return false
+}
+
+// optional is the check of the settings that accept any value
+func optional(string) error {
+	return nil
+}
+
+// requiredBy returns a check that rejects the empty values unless the setting is optional
+func requiredBy(name string, optional bool) func(value string) error {
+	if optional {
+		return func(string) error { return nil }
+	}
+	return required(name)
+}
+
+// required returns a check that rejects the empty values
+func required(name string) func(value string) error {
+	return func(value string) error {
+		if value == "" {
+			return fmt.Errorf("%s is required", name)
+		}
+		return nil
+	}
+}
+
+// checkPort rejects the values that are not a valid port
+func checkPort(value string) error {
+	if port, err := strconv.ParseUint(value, 10, 16); err != nil || port == 0 {
+		return fmt.Errorf("\"%s\" is not a valid port", value)
+	}
+	return nil
+}
+
+// checkBool rejects the values that are not empty, true or false
+func checkBool(value string) error {
+	if _, err := strconv.ParseBool(value); value != "" && err != nil {
+		return fmt.Errorf("\"%s\" is not true or false", value)
+	}
+	return nil
+}
+
+// optionalFile returns a check that accepts the empty values when the file is optional
+func optionalFile(optional func() bool) func(value string) error {
+	return func(value string) error {
+		if value == "" && optional() {
+			return nil
+		}
+		return checkFile(value)
+	}
+}
+
+// checkFile rejects the values that are not an existing file
+func checkFile(value string) error {
+	if value == "" {
+		return errors.New("the file is required by the ssl mode")
+	}
+	info, err := os.Stat(value)
+	if err != nil {
+		return err
+	}
+	if info.IsDir() {
+		return fmt.Errorf("%s is a directory", value)
+	}
+	return nil
+}
+
+// prompter reads the answers of the prompts
+type prompter struct {
+	in  *bufio.Reader
+	out io.Writer
+	// terminal is the file descriptor of the terminal used to read the secrets without echo, -1 without terminal
+	terminal int
+}
+
+// newPrompter returns a prompter reading from the input, the secrets are read without echo when it is a terminal
+func newPrompter(in io.Reader, out io.Writer) *prompter {
+	p := &prompter{in: bufio.NewReader(in), out: out, terminal: -1}
+	if file, ok := in.(*os.File); ok && term.IsTerminal(int(file.Fd())) {
+		p.terminal = int(file.Fd())
+	}
+	return p
+}
+
+// ask prints the prompt with the default value and returns the answer or the default value when the answer is empty
+func (p *prompter) ask(label string, defaultValue string, secret bool) (string, error) {
+	if defaultValue != "" && !secret {
+		fmt.Fprintf(p.out, "%s [%s]: ", label, defaultValue)
+	} else {
+		fmt.Fprintf(p.out, "%s: ", label)
+	}
+	if secret && p.terminal >= 0 {
+		answer, err := term.ReadPassword(p.terminal)
+		fmt.Fprintln(p.out)
+		if err != nil {
+			return "", err
+		}
+		return string(answer), nil
+	}
+	answer, err := p.in.ReadString('\n')
+	if err != nil && (err != io.EOF || answer == "") {
+		return "", errInputEnded
+	}
+	if answer = strings.TrimSpace(answer); answer == "" {
+		return defaultValue, nil
+	}
+	return answer, nil
+}
+
+// warn prints the reason an answer was rejected
+func (p *prompter) warn(err error) {
+	if ex, ok := err.(errorex.EX); ok && ex.Detail() != "" {
+		fmt.Fprintf(p.out, "  %s\n", ex.Detail())
+		return
+	}
+	fmt.Fprintf(p.out, "  %s\n", err.Error())
+}
@@ -0,0 +1,272 @@
+/*
+ * © 2023 fkmatsuda
+
+ * This file is licensed under the terms of the MIT license. Permission is hereby
+ * granted, free of charge, to any person obtaining a copy of this software and
+ * associated documentation files (the "Software"), to deal in the Software without
+ * restriction, including without limitation the rights to use, copy, modify,
+ * merge, publish, and/or distribute copies of the Software, and to permit persons
+ * to whom the Software is furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS," WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY,
+ * WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+ * CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ *
+ */
+
+// Command dbconfig loads a database configuration like the dbconfig library does and validates, prints or explains it
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/fkmatsuda-dev/commons/errorex"
+	"github.com/fkmatsuda-dev/dbconfig"
+)
+
+// exit codes of the command
+const (
+	exitOK      = 0
+	exitFailure = 1
+	exitUsage   = 2
+)
+
+const usage = `Usage: dbconfig <command> [flags]
+
+Commands:
+  validate   load and validate the configuration, exits with 1 when it is invalid
+  show       print the configuration with the password redacted
+  dsn        print the data source name of the configuration for a database/sql driver
+  explain    print where each value of the configuration came from
+  diff       compare two configurations, exits with 1 when they are different
+  init       write a new configuration file, asking for the settings
+  convert    convert a configuration between files, environment variables and database URLs
+  exec       run a database client with the credentials of the configuration, dbconfig exec -- psql
+
+The configuration is loaded like dbconfig.LoadConfig from the -path directory, from the -file
+configuration file or, with -env, from the environment variables only.
+Run dbconfig <command> -h for the flags of the command.
+`
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+// run runs the command line and returns the exit code
+func run(args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) int {
+	if len(args) == 0 {
+		fmt.Fprint(stderr, usage)
+		return exitUsage
+	}
+	switch args[0] {
+	case "validate":
+		return runValidate(args[1:], stdout, stderr)
+	case "show":
+		return runShow(args[1:], stdout, stderr)
+	case "dsn":
+		return runDSN(args[1:], stdout, stderr)
+	case "explain":
+		return runExplain(args[1:], stdout, stderr)
+	case "exec":
+		return runExec(args[1:], stdin, stdout, stderr)
+	case "convert":
+		return runConvert(args[1:], stdout, stderr)
+	case "diff":
+		return runDiff(args[1:], stdout, stderr)
+	case "init":
+		return runInit(args[1:], stdin, stdout, stderr)
+	case "help", "-h", "-help", "--help":
+		fmt.Fprint(stdout, usage)
+		return exitOK
+	}
+	fmt.Fprintf(stderr, "dbconfig: unknown command %q\n\n%s", args[0], usage)
+	return exitUsage
+}
+
+// source is the configuration source selected by the common flags
+type source struct {
+	path string
+	file string
+	env  bool
+}
+
+// newFlagSet returns the flag set of the command with the configuration source flags
+func newFlagSet(name string, stderr io.Writer) (*flag.FlagSet, *source) {
+	flags := flag.NewFlagSet("dbconfig "+name, flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	src := &source{}
+	flags.StringVar(&src.path, "path", ".",
+		"directory of the dbconfig.json, dbconfig.yaml, dbconfig.yml or dbconfig.toml file")
+	flags.StringVar(&src.file, "file", "", "configuration file to load instead of searching the -path directory")
+	flags.BoolVar(&src.env, "env", false, "load the configuration from the environment variables only")
+	return flags, src
+}
+
+// parseFlags parses the command flags, the exit code is returned when the command must stop
+func parseFlags(flags *flag.FlagSet, args []string) (int, bool) {
+	if err := flags.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return exitOK, false
+		}
+		return exitUsage, false
+	}
+	if flags.NArg() > 0 {
+		fmt.Fprintf(flags.Output(), "%s: unexpected argument %q\n", flags.Name(), flags.Arg(0))
+		return exitUsage, false
+	}
+	return exitOK, true
+}
+
+// load loads the configuration from the selected source
+func (s *source) load() (dbconfig.Config, error) {
+	switch {
+	case s.env:
+		return dbconfig.LoadFromEnv()
+	case s.file != "":
+		return dbconfig.LoadConfigFile(s.file)
+	}
+	return dbconfig.LoadConfig(s.path)
+}
+
+// explain loads the configuration from the selected source with the source of each value
+func (s *source) explain() (dbconfig.Config, []dbconfig.ValueSource, error) {
+	switch {
+	case s.env:
+		return dbconfig.ExplainEnv()
+	case s.file != "":
+		return dbconfig.ExplainFile(s.file)
+	}
+	return dbconfig.Explain(s.path)
+}
+
+func runValidate(args []string, stdout io.Writer, stderr io.Writer) int {
+	flags, src := newFlagSet("validate", stderr)
+	if code, ok := parseFlags(flags, args); !ok {
+		return code
+	}
+	config, err := src.load()
+	if err == nil {
+		err = config.Validate()
+	}
+	if err != nil {
+		printError(stderr, err)
+		return exitFailure
+	}
+	fmt.Fprintln(stdout, "configuration is valid")
+	return exitOK
+}
+
+func runShow(args []string, stdout io.Writer, stderr io.Writer) int {
+	flags, src := newFlagSet("show", stderr)
+	format := flags.String("format", "json", "output format: json, yaml, toml, env or table")
+	if code, ok := parseFlags(flags, args); !ok {
+		return code
+	}
+	config, err := src.load()
+	if err != nil {
+		printError(stderr, err)
+		return exitFailure
+	}
+	config = config.Redacted()
+	if *format == "table" {
+		table := tabwriter.NewWriter(stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(table, "NAME\tVALUE")
+		for _, variable := range config.EnvVars() {
+			name, value, _ := strings.Cut(variable, "=")
+			fmt.Fprintf(table, "%s\t%s\n", name, value)
+		}
+		table.Flush()
+		return exitOK
+	}
+	content, err := config.Encode(*format)
+	if err != nil {
+		printError(stderr, err)
+		return exitUsage
+	}
+	fmt.Fprint(stdout, string(content))
+	return exitOK
+}
+
+func runDSN(args []string, stdout io.Writer, stderr io.Writer) int {
+	flags, src := newFlagSet("dsn", stderr)
+	driver := flags.String("driver", "", "driver name: pgx, postgres, yugabytedb, mysql, sqlite3, sqlite, sqlserver, "+
+		"mssql, oracle, mongodb, redis or clickhouse, defaults to the driver of the type")
+	if code, ok := parseFlags(flags, args); !ok {
+		return code
+	}
+	config, err := src.load()
+	if err != nil {
+		printError(stderr, err)
+		return exitFailure
+	}
+	if *driver == "" {
+		*driver = defaultDriver(config.Type)
+	}
+	dsn, err := config.DSN(*driver)
+	if err != nil {
+		printError(stderr, err)
+		return exitFailure
+	}
+	fmt.Fprintln(stdout, dsn)
+	return exitOK
+}
+
+func runExplain(args []string, stdout io.Writer, stderr io.Writer) int {
+	flags, src := newFlagSet("explain", stderr)
+	if code, ok := parseFlags(flags, args); !ok {
+		return code
+	}
+	_, values, err := src.explain()
+	if err != nil {
+		printError(stderr, err)
+		return exitFailure
+	}
+	table := tabwriter.NewWriter(stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(table, "FIELD\tVALUE\tSOURCE")
+	for _, value := range values {
+		fmt.Fprintf(table, "%s\t%s\t%s\n", value.Field, value.Value, value.Source)
+	}
+	table.Flush()
+	return exitOK
+}
+
+// defaultDriver returns the database/sql driver used by the dsn command when -driver is not set
+func defaultDriver(dbType dbconfig.DbType) string {
+	switch dbType.Family() {
+	case dbconfig.DbTypeMysql:
+		return "mysql"
+	case dbconfig.DbTypeSqlite:
+		return "sqlite"
+	case dbconfig.DbTypeSqlserver:
+		return "sqlserver"
+	case dbconfig.DbTypeOracle:
+		return "oracle"
+	case dbconfig.DbTypeMongodb:
+		return "mongodb"
+	case dbconfig.DbTypeRedis:
+		return "redis"
+	case dbconfig.DbTypeClickhouse:
+		return "clickhouse"
+	}
+	return "pgx"
+}
+
+// printError prints the error with its dbconfig error code
+func printError(stderr io.Writer, err error) {
+	if ex, ok := err.(errorex.EX); ok && ex.Detail() != "" {
+		fmt.Fprintf(stderr, "%s: %s\n", ex.Error(), ex.Detail())
+		return
+	}
+	fmt.Fprintln(stderr, err)
+}
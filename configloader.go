@@ -23,37 +23,100 @@
 package dbconfig
 
 import (
+	"bytes"
+	"crypto/ed25519"
 	"encoding/json"
 	"fmt"
 	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
 
+	"github.com/BurntSushi/toml"
 	"github.com/fkmatsuda-dev/commons/errorex"
 	"github.com/fkmatsuda-dev/commons/files"
-	"github.com/fkmatsuda-dev/env"
+	"gopkg.in/yaml.v3"
 )
 
 var (
 	// configFormarts is the order of the configuration file formats to be loaded
-	configFormats = []string{"json"}
+	configFormats = []string{"json", "yaml", "yml", "toml"}
 )
 
+// LoadOptions are the options of LoadConfigWithOptions
+type LoadOptions struct {
+	// TrustedKeys are the ed25519 public keys accepted for the configuration file signatures, the keys of the
+	// DBCONFIG_TRUSTED_KEYS environment variable are added to them
+	TrustedKeys []ed25519.PublicKey
+	// RequireSignature requires a valid dbconfig.<format>.sig detached signature for the configuration file or the
+	// dbconfig systemd credential, the signature is also required when any trusted key is set. The environment
+	// variables are not loaded when a signature is required
+	RequireSignature bool
+	// PermissionCheck is the mode of the permission checks of the configuration and SSL files, when it is not set
+	// the DBCONFIG_PERMISSION_CHECK environment variable is used and then PermissionCheckOff
+	PermissionCheck PermissionCheck
+	// PermissionWarning receives the permission violations in the warn mode, they are logged with the standard logger
+	// when it is not set
+	PermissionWarning func(violation error)
+	// SkipPasswordLookup keeps the password empty instead of running the password command or reading the libpq
+	// password file, so the configuration can be converted without adding the password to it
+	SkipPasswordLookup bool
+}
+
+// envLookup returns the value of a DB_* variable and whether it is set
+type envLookup func(name string) (string, bool)
+
 // LoadConfig loads the database settings and returns a struct Config
-// tries to load the configuration from the dbconfig.json file and if the file does not exist it will try to load it from the environment variables
+// tries to load the configuration from the dbconfig.json, dbconfig.yaml, dbconfig.yml or dbconfig.toml file and if
+// the file does not exist it will try to load it from the dbconfig systemd credential and then from the environment
+// variables
 func LoadConfig(path string) (Config, error) {
+	return LoadConfigWithOptions(path, LoadOptions{})
+}
+
+// LoadConfigWithOptions loads the database settings like LoadConfig with the given options
+func LoadConfigWithOptions(path string, options LoadOptions) (Config, error) {
 	// search for the configuration file
 	configFile, err := searchConfigFile(path)
 	if err != nil {
-		if errorex.IS(err, ErrorCodeConfigFileNotFound) {
+		if !errorex.IS(err, ErrorCodeConfigFileNotFound) {
+			return Config{}, err
+		}
+		// search for the configuration credential
+		credentialFile, chk := searchCredentialConfigFile()
+		if !chk {
+			// the environment variables are not signed, they must not replace a removed signed file
+			_, required, err := signatureRequirement(options)
+			if err != nil {
+				return Config{}, err
+			}
+			if required {
+				return Config{}, errorex.New(
+					ErrorCodeConfigSignatureNotFound,
+					signatureNotFound,
+					"no signed configuration file or credential found",
+				)
+			}
 			// try to load the environment variables
-			return LoadFromEnv()
+			config, err := LoadFromEnv()
+			if err != nil {
+				return Config{}, err
+			}
+			return config, applyPermissionCheck("", false, config, options)
 		}
-		return Config{}, err
+		configFile = credentialFile
 	}
 	// load the configuration file
-	return loadConfigFile(configFile)
+	return loadConfigFile(configFile, options)
+}
+
+// LoadConfigFile loads the database settings of the given json, yaml or toml configuration file, with the same
+// decryption, signature and permission checks of LoadConfig
+func LoadConfigFile(file string) (Config, error) {
+	return loadConfigFile(file, LoadOptions{})
 }
 
-func loadConfigFile(file string) (Config, error) {
+func loadConfigFile(file string, options LoadOptions) (Config, error) {
 	// read the configuration file
 	fileContent, err := files.ReadFile(file)
 	if err != nil {
@@ -63,9 +126,36 @@ func loadConfigFile(file string) (Config, error) {
 			err.Error(),
 		)
 	}
+	// verify the signature of the read content
+	if err := verifyConfigSignature(file, []byte(fileContent), options); err != nil {
+		return Config{}, err
+	}
+	// decode the configuration file
+	document, err := decodeConfigDocument(file, []byte(fileContent))
+	if err != nil {
+		return Config{}, errorex.New(
+			ErrorCodeConfigFileParseError,
+			"Configuration file parse error",
+			err.Error(),
+		)
+	}
+	// the decryption replaces the encrypted values of the document
+	plaintextPassword := hasPlaintextPassword(document)
+	// decrypt the SOPS encrypted files and the encrypted values
+	if isSopsDocument(document) {
+		document, err = decryptSopsFile(file, []byte(fileContent))
+	} else {
+		document, err = decryptDocument(document)
+	}
+	if err != nil {
+		return Config{}, err
+	}
 	// Unmarshal the configuration file
 	var config Config
-	err = json.Unmarshal([]byte(fileContent), &config)
+	content, err := json.Marshal(document)
+	if err == nil {
+		err = json.Unmarshal(content, &config)
+	}
 	if err != nil {
 		return Config{}, errorex.New(
 			ErrorCodeConfigFileParseError,
@@ -73,7 +163,22 @@ func loadConfigFile(file string) (Config, error) {
 			err.Error(),
 		)
 	}
-	return config, nil
+	// check the permissions of the configuration and SSL files
+	if err := applyPermissionCheck(file, plaintextPassword, config, options); err != nil {
+		return Config{}, err
+	}
+	if options.SkipPasswordLookup {
+		return config, nil
+	}
+	// run the password command when the password is not set
+	if config.Password == "" && config.PasswordCommand != "" {
+		config.Password, err = runPasswordCommand(config.PasswordCommand)
+		if err != nil {
+			return Config{}, err
+		}
+	}
+	// look up the password in the libpq password file when it is still not set
+	return resolvePgpassPassword(config), nil
 }
 
 const (
@@ -82,121 +187,199 @@ const (
 )
 
 // LoadFromEnv loads the database settings from the environment variables and returns a struct Config
+// each setting is read from its DB_* variable, then from the systemd credential with the same name in lower case with
+// dashes (DB_PASSWORD from db-password) inside $CREDENTIALS_DIRECTORY and then from the DB_SECRET_JSON secret payload.
+// When none of them sets the password the output of DB_PASSWORD_COMMAND is used as the password, the PostgreSQL family
+// passwords that are still not set are looked up in the libpq password file. The options of each database type are
+// read from its DB_<TYPE>_* variables, listed in the Database types section of the README
 func LoadFromEnv() (Config, error) {
+	return loadEnvConfig(lookupEnv, true)
+}
+
+// loadEnvConfig loads the database settings from the DB_* variables returned by the lookup function, the password
+// command and the libpq password file are only used when resolvePassword is set
+func loadEnvConfig(lookup envLookup, resolvePassword bool) (Config, error) {
 	config := Config{}
+	// load the secret payload
+	if secret, chk := lookup("DB_SECRET_JSON"); chk {
+		var err error
+		config, err = ParseSecretJSON([]byte(secret))
+		if err != nil {
+			return Config{}, errorex.New(ErrorCodeEnvConfigParseError, configurationParseError, err.Error())
+		}
+	}
+
 	// load the database type
-	strType, chk := env.ChkString("DB_TYPE")
-	if !chk {
+	strType, chk := lookup("DB_TYPE")
+	if chk {
+		dbType, err := ParseDbType(strType)
+		if err != nil {
+			return Config{}, errorex.New(ErrorCodeEnvConfigParseError, configurationParseError, err.Error())
+		}
+		config.Type = dbType
+	} else if config.Type == 0 {
 		return Config{}, errorex.New(ErrorCodeEnvConfigNotLoaded, envNotLoaded, "DB_TYPE environment variable not found")
 	}
-	dbType, err := ParseDbType(strType)
-	if err != nil {
-		return Config{}, errorex.New(ErrorCodeEnvConfigParseError, configurationParseError, err.Error())
+	// SQLite has no server, only the database file and the connection options are loaded
+	if config.Type == DbTypeSqlite {
+		return loadSqliteEnv(lookup, config)
 	}
 
 	// load the database host
-	dbHost, chk := env.ChkString("DB_HOST")
-	if !chk {
-		return Config{}, errorex.New(
-			ErrorCodeEnvConfigNotLoaded,
-			envNotLoaded,
-			"DB_HOST environment variable not found",
-		)
+	// Oracle connects to the service, SID or TNS alias instead of the database and the TNS alias gives the host
+	dbHost, err := envValue(lookup, "DB_HOST", config.Host)
+	if err != nil && config.Type != DbTypeOracle {
+		return Config{}, err
 	}
 
 	// load the database port
-	dbPort, err := env.Int("DB_PORT", 5432)
-	if err != nil {
-		return Config{}, errorex.New(ErrorCodeEnvConfigParseError, configurationParseError, err.Error())
+	dbPort := config.Type.DefaultPort()
+	if config.Port != 0 {
+		dbPort = config.Port
+	}
+	if strPort, chk := lookup("DB_PORT"); chk {
+		port, err := strconv.ParseUint(strPort, 10, 16)
+		if err != nil || port == 0 {
+			return Config{}, errorex.New(
+				ErrorCodeEnvConfigParseError,
+				configurationParseError,
+				fmt.Sprintf("\"%s\" value for DB_PORT is invalid", strPort),
+			)
+		}
+		dbPort = uint16(port)
 	}
 
 	// load the database name
-	dbDatabase, chk := env.ChkString("DB_DATABASE")
-	if !chk {
-		return Config{}, errorex.New(
-			ErrorCodeEnvConfigNotLoaded,
-			envNotLoaded,
-			"DB_DATABASE environment variable not found",
-		)
+	// MongoDB authenticates with the auth source (or admin) database, Redis uses the database 0 and ClickHouse the
+	// default database when the database is not set
+	dbDatabase, err := envValue(lookup, "DB_DATABASE", config.Database)
+	if err != nil && config.Type != DbTypeOracle && !config.Type.optionalCredentials() {
+		return Config{}, err
 	}
 
-	// load the database user
-	dbUser, chk := env.ChkString("DB_USER")
-	if !chk {
-		return Config{}, errorex.New(
-			ErrorCodeEnvConfigNotLoaded,
-			envNotLoaded,
-			"DB_USER environment variable not found",
-		)
+	// load the database user, the MongoDB and Redis servers without authentication have no user and ClickHouse uses
+	// the default user
+	dbUser, err := envValue(lookup, "DB_USER", config.User)
+	if err != nil && !config.Type.optionalCredentials() {
+		return Config{}, err
 	}
 
-	// load the database password
-	dbPassword, chk := env.ChkString("DB_PASSWORD")
+	// load the database password, the DB_PASSWORD_COMMAND output is only used when neither DB_PASSWORD nor the
+	// secret payload set the password
+	if command, chk := lookup("DB_PASSWORD_COMMAND"); chk {
+		config.PasswordCommand = command
+	}
+	dbPassword, chk := lookup("DB_PASSWORD")
 	if !chk {
-		return Config{}, errorex.New(
-			ErrorCodeEnvConfigNotLoaded,
-			envNotLoaded,
-			"DB_PASSWORD environment variable not found",
-		)
+		dbPassword = config.Password
+	}
+	if !chk && resolvePassword && dbPassword == "" {
+		if config.PasswordCommand != "" {
+			dbPassword, err = runPasswordCommand(config.PasswordCommand)
+		} else {
+			fallback := resolvePgpassPassword(Config{
+				Type:     config.Type,
+				Host:     dbHost,
+				Port:     dbPort,
+				User:     dbUser,
+				Database: dbDatabase,
+			}).Password
+			dbPassword, err = envValue(lookup, "DB_PASSWORD", fallback)
+		}
+	}
+	// the password is checked by Validate for MongoDB, the X.509 and AWS mechanisms do not use it, and it is optional
+	// for Redis and ClickHouse
+	if err != nil && (!config.Type.optionalCredentials() || !errorex.IS(err, ErrorCodeEnvConfigNotLoaded)) {
+		return Config{}, err
 	}
 	// fill the configuration struct
-	config.Type = dbType
 	config.Host = dbHost
-	config.Port = uint16(dbPort)
+	config.Port = dbPort
 	config.Database = dbDatabase
 	config.User = dbUser
 	config.Password = dbPassword
 
-	return loadSSL(chk, err, config)
+	config, err = loadSSL(lookup, config)
+	if err != nil {
+		return Config{}, err
+	}
+	// load the options of the database type
+	switch config.Type {
+	case DbTypeSqlserver:
+		config = loadSqlserverEnv(lookup, config)
+	case DbTypeOracle:
+		return loadOracleEnv(lookup, config)
+	case DbTypeYugabytedb:
+		return loadYugabyteEnv(lookup, config)
+	case DbTypeMongodb:
+		return loadMongodbEnv(lookup, config)
+	case DbTypeRedis:
+		return loadRedisEnv(lookup, config)
+	case DbTypeClickhouse:
+		return loadClickhouseEnv(lookup, config)
+	}
+	return config, nil
+}
+
+// envValue returns the value of the environment variable or the fallback value when the variable is not set
+// an error is returned when both are empty
+func envValue(lookup envLookup, name string, fallback string) (string, error) {
+	if value, chk := lookup(name); chk {
+		return value, nil
+	}
+	if fallback != "" {
+		return fallback, nil
+	}
+	return "", errorex.New(
+		ErrorCodeEnvConfigNotLoaded,
+		envNotLoaded,
+		fmt.Sprintf("%s environment variable not found", name),
+	)
 }
 
-func loadSSL(chk bool, err error, config Config) (Config, error) {
+func loadSSL(lookup envLookup, config Config) (Config, error) {
+	// the ssl settings already present in the configuration are used as fallback values
+	fallback := SSLConfig{Mode: SSLModeDisable}
+	if config.SSL != nil {
+		fallback = *config.SSL
+	}
+
 	// load the database ssl mode
-	strSSLMode, chk := env.ChkString("DB_SSL_MODE")
+	strSSLMode, chk := lookup("DB_SSL_MODE")
 	if !chk {
-		strSSLMode = "disable"
+		strSSLMode = fallback.Mode.String()
 	}
 	sslMode, err := ParseSSLMode(strSSLMode)
 	if err != nil {
 		return Config{}, errorex.New(ErrorCodeEnvConfigParseError, configurationParseError, err.Error())
 	}
 
-	// if ssl mode is verify-full, load the ssl certificate and key
+	// if ssl mode is verify-full, load the ssl certificate and key, SQL Server and Oracle have no client certificates
+	// and they are optional for the types that verify the server with the system roots
 	var sslCert, sslKey string
-	if sslMode == SSLModeVerifyFull {
-		sslCert, chk = env.ChkString("DB_SSL_CERT")
-		if !chk {
-			return Config{}, errorex.New(
-				ErrorCodeEnvConfigNotLoaded,
-				envNotLoaded,
-				"DB_SSL_CERT environment variable not found",
-			)
+	if sslMode == SSLModeVerifyFull && config.Type.clientCertificates() {
+		sslCert, err = envValue(lookup, "DB_SSL_CERT", fallback.Cert)
+		if err != nil && !config.Type.systemRoots() {
+			return Config{}, err
 		}
-		sslKey, chk = env.ChkString("DB_SSL_KEY")
-		if !chk {
-			return Config{}, errorex.New(
-				ErrorCodeEnvConfigNotLoaded,
-				envNotLoaded,
-				"DB_SSL_KEY environment variable not found",
-			)
+		sslKey, err = envValue(lookup, "DB_SSL_KEY", fallback.Key)
+		if err != nil && !config.Type.systemRoots() {
+			return Config{}, err
 		}
 	}
 
 	// if ssl mode is verify-ca or verify-full, load the ssl root certificate
 	var sslCa string
 	if sslMode == SSLModeVerifyCA || sslMode == SSLModeVerifyFull {
-		sslCa, chk = env.ChkString("DB_SSL_CA")
-		if !chk {
-			return Config{}, errorex.New(
-				ErrorCodeEnvConfigNotLoaded,
-				envNotLoaded,
-				"DB_SSL_CA environment variable not found",
-			)
+		sslCa, err = envValue(lookup, "DB_SSL_CA", fallback.Ca)
+		// SQL Server, Oracle and MongoDB verify the server certificate with the system roots when the ca is not set
+		if err != nil && !config.Type.systemRoots() {
+			return Config{}, err
 		}
 	}
 
-	// if ssl mode is verify-ca or verify-full, fill the ssl struct
-	if sslMode == SSLModeVerifyCA || sslMode == SSLModeVerifyFull {
+	// if ssl mode is not disable, fill the ssl struct
+	if sslMode != SSLModeDisable {
 		sslConfig := SSLConfig{
 			Mode: sslMode,
 			Ca:   sslCa,
@@ -213,6 +396,30 @@ func loadSSL(chk bool, err error, config Config) (Config, error) {
 	return config, nil
 }
 
+// decodeConfigDocument decodes the configuration file content according to the file extension
+func decodeConfigDocument(file string, content []byte) (interface{}, error) {
+	var document interface{}
+	switch strings.ToLower(filepath.Ext(file)) {
+	case ".yaml", ".yml":
+		if err := yaml.Unmarshal(content, &document); err != nil {
+			return nil, err
+		}
+	case ".toml":
+		var table map[string]interface{}
+		if err := toml.Unmarshal(content, &table); err != nil {
+			return nil, err
+		}
+		document = table
+	default:
+		decoder := json.NewDecoder(bytes.NewReader(content))
+		decoder.UseNumber()
+		if err := decoder.Decode(&document); err != nil {
+			return nil, err
+		}
+	}
+	return document, nil
+}
+
 func searchConfigFile(path string) (string, error) {
 	// iterate over the configuration file formats
 	for _, format := range configFormats {
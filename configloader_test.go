@@ -26,6 +26,7 @@ import (
 	"github.com/fkmatsuda-dev/commons/errorex"
 	"github.com/fkmatsuda-dev/commons/files"
 	"os"
+	"reflect"
 	"testing"
 )
 
@@ -36,7 +37,18 @@ func (c Config) compare(c2 Config) bool {
 		c.User == c2.User &&
 		c.Password == c2.Password &&
 		c.Database == c2.Database &&
-		compareSSLConfig(c.SSL, c2.SSL)
+		compareSSLConfig(c.SSL, c2.SSL) &&
+		(c.Sqlite == nil) == (c2.Sqlite == nil) &&
+		(c.Sqlite == nil || *c.Sqlite == *c2.Sqlite) &&
+		(c.Sqlserver == nil) == (c2.Sqlserver == nil) &&
+		(c.Sqlserver == nil || *c.Sqlserver == *c2.Sqlserver) &&
+		(c.Oracle == nil) == (c2.Oracle == nil) &&
+		(c.Oracle == nil || *c.Oracle == *c2.Oracle) &&
+		(c.Yugabyte == nil) == (c2.Yugabyte == nil) &&
+		(c.Yugabyte == nil || *c.Yugabyte == *c2.Yugabyte) &&
+		reflect.DeepEqual(c.Mongodb, c2.Mongodb) &&
+		reflect.DeepEqual(c.Redis, c2.Redis) &&
+		reflect.DeepEqual(c.Clickhouse, c2.Clickhouse)
 }
 
 func compareSSLConfig(ssl *SSLConfig, ssl2 *SSLConfig) bool {
@@ -471,6 +483,23 @@ func TestLoadConfig(t *testing.T) {
 		}
 	})
 
+	// Test load by environment variables with out of range port
+	t.Run("Test load by environment variables with out of range port", func(t *testing.T) {
+		t.Setenv("DB_TYPE", "POSTGRESQL")
+		t.Setenv("DB_HOST", "localhost")
+		t.Setenv("DB_USER", "postgres")
+		t.Setenv("DB_PASSWORD", "postgres")
+		t.Setenv("DB_DATABASE", "postgres")
+		t.Setenv("DB_SSL_MODE", "disable")
+
+		for _, port := range []string{"70000", "0", "-1"} {
+			t.Setenv("DB_PORT", port)
+			if _, err := LoadConfig(dirName); !errorex.IS(err, ErrorCodeEnvConfigParseError) {
+				t.Errorf("Environment configuration parse error expected for the %s port", port)
+			}
+		}
+	})
+
 	// Test load by environment variables without user
 	t.Run("Test load by environment variables without user", func(t *testing.T) {
 		t.Setenv("DB_TYPE", "POSTGRESQL")
@@ -659,4 +688,89 @@ func TestLoadConfig(t *testing.T) {
 		},
 	)
 
+	// Test load by environment variables with secret payload
+	t.Run("Test load by environment variables with secret payload", func(t *testing.T) {
+		t.Setenv("DB_SECRET_JSON", `{
+			"engine": "postgres",
+			"host": "db.example.com",
+			"port": 5433,
+			"username": "app",
+			"password": "s3cr3t",
+			"dbname": "orders"
+		}`)
+		t.Setenv("DB_DATABASE", "reports")
+
+		expected := Config{
+			Type:     DbTypePostgres,
+			Host:     "db.example.com",
+			Port:     5433,
+			User:     "app",
+			Password: "s3cr3t",
+			Database: "reports",
+		}
+
+		// load the configuration
+		loadedConfig, err := LoadConfig(dirName)
+		if err != nil {
+			t.Errorf("Error loading secret payload: %s", err.Error())
+			return
+		}
+
+		// the DB_* environment variables must override the secret payload
+		if !expected.compare(loadedConfig) {
+			t.Errorf("The loaded configuration is different from the sample configuration")
+			return
+		}
+	})
+
+	// Test load by environment variables with invalid secret payload
+	t.Run("Test load by environment variables with invalid secret payload", func(t *testing.T) {
+		t.Setenv("DB_SECRET_JSON", "invalid")
+
+		// load the configuration
+		_, err := LoadConfig(dirName)
+		if !errorex.IS(err, ErrorCodeEnvConfigParseError) {
+			t.Errorf("Environment configuration parse error expected")
+			return
+		}
+	})
+
+}
+
+// TestLoadFromEnvSSLModes every ssl mode except disable must be kept in the loaded configuration
+func TestLoadFromEnvSSLModes(t *testing.T) {
+	t.Setenv("DB_TYPE", "POSTGRESQL")
+	t.Setenv("DB_HOST", "localhost")
+	t.Setenv("DB_PORT", "5432")
+	t.Setenv("DB_USER", "postgres")
+	t.Setenv("DB_PASSWORD", "postgres")
+	t.Setenv("DB_DATABASE", "postgres")
+	t.Setenv("DB_SSL_CA", "ca.crt")
+	t.Setenv("DB_SSL_CERT", "client.crt")
+	t.Setenv("DB_SSL_KEY", "client.key")
+
+	tests := []struct {
+		mode     string
+		expected *SSLConfig
+	}{
+		{"disable", nil},
+		{"allow", &SSLConfig{Mode: SSLModeAllow}},
+		{"prefer", &SSLConfig{Mode: SSLModePrefer}},
+		{"require", &SSLConfig{Mode: SSLModeRequire}},
+		{"verify-ca", &SSLConfig{Mode: SSLModeVerifyCA, Ca: "ca.crt"}},
+		{"verify-full", &SSLConfig{Mode: SSLModeVerifyFull, Ca: "ca.crt", Cert: "client.crt", Key: "client.key"}},
+	}
+	for _, test := range tests {
+		t.Run(test.mode, func(t *testing.T) {
+			t.Setenv("DB_SSL_MODE", test.mode)
+			config, err := LoadFromEnv()
+			if err != nil {
+				t.Errorf("Error loading environment configuration: %s", err.Error())
+				return
+			}
+			if !compareSSLConfig(test.expected, config.SSL) {
+				t.Errorf("The ssl configuration %+v expected, got %+v", test.expected, config.SSL)
+			}
+		})
+	}
 }
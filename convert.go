@@ -0,0 +1,68 @@
+/*
+ * © 2023 fkmatsuda
+
+ * This file is licensed under the terms of the MIT license. Permission is hereby
+ * granted, free of charge, to any person obtaining a copy of this software and
+ * associated documentation files (the "Software"), to deal in the Software without
+ * restriction, including without limitation the rights to use, copy, modify,
+ * merge, publish, and/or distribute copies of the Software, and to permit persons
+ * to whom the Software is furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS," WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY,
+ * WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+ * CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ *
+ */
+
+package dbconfig
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// LoadSource loads the configuration from a source: "env" for the environment variables like LoadFromEnv, a database
+// URL like ParseURL, a .env file like LoadEnvFile or a json, yaml or toml configuration file like LoadConfigFile
+func LoadSource(source string) (Config, error) {
+	return loadSource(source, true)
+}
+
+// Convert loads the configuration from the source like LoadSource and encodes it in the json, yaml, toml, env, url or
+// shell format of Config.Encode. The password commands are kept and not run, and the libpq password file is not
+// read, so the converted configuration has a password only when the source has it
+func Convert(source string, format string) ([]byte, error) {
+	config, err := loadSource(source, false)
+	if err != nil {
+		return nil, err
+	}
+	return config.Encode(format)
+}
+
+func loadSource(source string, resolvePassword bool) (Config, error) {
+	switch {
+	case source == "env":
+		return loadEnvConfig(lookupEnv, resolvePassword)
+	case isURLSource(source):
+		return ParseURL(source)
+	case strings.EqualFold(filepath.Ext(source), ".env"):
+		return loadEnvFile(source, resolvePassword)
+	}
+	return loadConfigFile(source, LoadOptions{SkipPasswordLookup: !resolvePassword})
+}
+
+// isURLSource checks if the source is a database URL, like the sqlite:path URLs without // it starts with a scheme
+// of URLSchemeValue followed by a colon
+func isURLSource(source string) bool {
+	if strings.Contains(source, "://") {
+		return true
+	}
+	scheme, _, found := strings.Cut(source, ":")
+	_, ok := URLSchemeValue[strings.ToLower(scheme)]
+	return found && ok
+}
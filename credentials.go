@@ -0,0 +1,82 @@
+/*
+ * © 2023 fkmatsuda
+
+ * This file is licensed under the terms of the MIT license. Permission is hereby
+ * granted, free of charge, to any person obtaining a copy of this software and
+ * associated documentation files (the "Software"), to deal in the Software without
+ * restriction, including without limitation the rights to use, copy, modify,
+ * merge, publish, and/or distribute copies of the Software, and to permit persons
+ * to whom the Software is furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS," WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY,
+ * WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+ * CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ *
+ */
+
+package dbconfig
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/fkmatsuda-dev/commons/files"
+	"github.com/fkmatsuda-dev/env"
+)
+
+// CredentialName returns the name of the systemd credential holding the value of the environment variable,
+// the name is the variable name in lower case with dashes, DB_PASSWORD is read from the db-password credential
+func CredentialName(envName string) string {
+	return strings.ReplaceAll(strings.ToLower(envName), "_", "-")
+}
+
+// credentialsDirectory returns the directory of the systemd credentials passed with LoadCredential=
+func credentialsDirectory() (string, bool) {
+	return env.ChkString("CREDENTIALS_DIRECTORY")
+}
+
+// readCredential reads a systemd credential without the trailing line break
+func readCredential(name string) (string, bool) {
+	dir, chk := credentialsDirectory()
+	if !chk {
+		return "", false
+	}
+	value, err := files.ReadFile(filepath.Join(dir, name))
+	if err != nil {
+		return "", false
+	}
+	value = strings.TrimRight(value, "\r\n")
+	return value, value != ""
+}
+
+// lookupEnv returns the value of the environment variable, when the variable is not set the value is read from
+// the matching systemd credential
+func lookupEnv(name string) (string, bool) {
+	if value, chk := env.ChkString(name); chk {
+		return value, true
+	}
+	return readCredential(CredentialName(name))
+}
+
+// searchCredentialConfigFile searches for a dbconfig credential inside the systemd credentials directory
+func searchCredentialConfigFile() (string, bool) {
+	dir, chk := credentialsDirectory()
+	if !chk {
+		return "", false
+	}
+	configFile, err := searchConfigFile(dir)
+	if err != nil {
+		return "", false
+	}
+	if info, err := os.Stat(configFile); err != nil || info.IsDir() {
+		return "", false
+	}
+	return configFile, true
+}
@@ -0,0 +1,182 @@
+/*
+ * © 2023 fkmatsuda
+
+ * This file is licensed under the terms of the MIT license. Permission is hereby
+ * granted, free of charge, to any person obtaining a copy of this software and
+ * associated documentation files (the "Software"), to deal in the Software without
+ * restriction, including without limitation the rights to use, copy, modify,
+ * merge, publish, and/or distribute copies of the Software, and to permit persons
+ * to whom the Software is furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS," WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY,
+ * WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+ * CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ *
+ */
+
+package dbconfig
+
+import (
+	"testing"
+
+	"github.com/fkmatsuda-dev/commons/files"
+)
+
+func TestSystemdCredentials(t *testing.T) {
+
+	// Create the configuration and credentials directories inside system temp directory
+	dirName, err := files.CreateTempDir()
+	if err != nil {
+		t.Errorf("Error creating temporary directory: %s", err.Error())
+		return
+	}
+	credentialsDir, err := files.CreateTempDir()
+	if err != nil {
+		t.Errorf("Error creating temporary directory: %s", err.Error())
+		return
+	}
+	defer func() {
+		_ = files.CleanupTempDirs()
+	}()
+
+	setBaseEnv := func(t *testing.T) {
+		t.Setenv("CREDENTIALS_DIRECTORY", credentialsDir)
+		t.Setenv("DB_TYPE", "POSTGRESQL")
+		t.Setenv("DB_HOST", "localhost")
+		t.Setenv("DB_USER", "postgres")
+		t.Setenv("DB_DATABASE", "postgres")
+	}
+
+	// write the db-password credential, systemd credentials usually end with a line break
+	if err := files.WriteFile(credentialsDir+"/db-password", "from-credential\n"); err != nil {
+		t.Errorf("Error writing credential: %s", err.Error())
+		return
+	}
+
+	// Test credential name
+	t.Run("Test credential name", func(t *testing.T) {
+		if name := CredentialName("DB_SSL_KEY"); name != "db-ssl-key" {
+			t.Errorf("db-ssl-key expected, got %s", name)
+			return
+		}
+	})
+
+	// Test password from credential
+	t.Run("Test password from credential", func(t *testing.T) {
+		setBaseEnv(t)
+
+		config, err := LoadFromEnv()
+		if err != nil {
+			t.Errorf("Error loading configuration: %s", err.Error())
+			return
+		}
+		if config.Password != "from-credential" {
+			t.Errorf("The password must be read from the db-password credential")
+			return
+		}
+	})
+
+	// Test environment variable takes precedence over credential
+	t.Run("Test environment variable takes precedence over credential", func(t *testing.T) {
+		setBaseEnv(t)
+		t.Setenv("DB_PASSWORD", "from-env")
+
+		config, err := LoadFromEnv()
+		if err != nil {
+			t.Errorf("Error loading configuration: %s", err.Error())
+			return
+		}
+		if config.Password != "from-env" {
+			t.Errorf("The password must be read from the DB_PASSWORD environment variable")
+			return
+		}
+	})
+
+	// Test credential takes precedence over secret payload
+	t.Run("Test credential takes precedence over secret payload", func(t *testing.T) {
+		setBaseEnv(t)
+		t.Setenv("DB_SECRET_JSON", `{"engine": "postgres", "password": "from-secret"}`)
+
+		config, err := LoadFromEnv()
+		if err != nil {
+			t.Errorf("Error loading configuration: %s", err.Error())
+			return
+		}
+		if config.Password != "from-credential" {
+			t.Errorf("The password must be read from the db-password credential")
+			return
+		}
+	})
+
+	// Test without credentials directory
+	t.Run("Test without credentials directory", func(t *testing.T) {
+		setBaseEnv(t)
+		t.Setenv("CREDENTIALS_DIRECTORY", "")
+
+		_, err := LoadFromEnv()
+		if err == nil {
+			t.Errorf("Error expected")
+			return
+		}
+	})
+
+	// Test dbconfig.json credential
+	t.Run("Test dbconfig.json credential", func(t *testing.T) {
+		t.Setenv("CREDENTIALS_DIRECTORY", credentialsDir)
+		t.Setenv("DB_TYPE", "MYSQL")
+
+		err := files.WriteFile(credentialsDir+"/dbconfig.json", `{
+			"type": "POSTGRESQL",
+			"host": "credential-host",
+			"port": 5432,
+			"user": "postgres",
+			"password": "postgres",
+			"database": "postgres"
+		}`)
+		if err != nil {
+			t.Errorf("Error writing credential: %s", err.Error())
+			return
+		}
+
+		// the dbconfig.json credential takes precedence over the environment variables
+		config, err := LoadConfig(dirName)
+		if err != nil {
+			t.Errorf("Error loading configuration: %s", err.Error())
+			return
+		}
+		if config.Host != "credential-host" || config.Type != DbTypePostgres {
+			t.Errorf("The configuration must be read from the dbconfig.json credential")
+			return
+		}
+
+		// the configuration file takes precedence over the dbconfig.json credential
+		err = files.WriteFile(dirName+"/dbconfig.json", `{
+			"type": "POSTGRESQL",
+			"host": "file-host",
+			"port": 5432,
+			"user": "postgres",
+			"password": "postgres",
+			"database": "postgres"
+		}`)
+		if err != nil {
+			t.Errorf("Error writing json configuration file: %s", err.Error())
+			return
+		}
+		config, err = LoadConfig(dirName)
+		if err != nil {
+			t.Errorf("Error loading configuration: %s", err.Error())
+			return
+		}
+		if config.Host != "file-host" {
+			t.Errorf("The configuration must be read from the configuration file")
+			return
+		}
+	})
+
+}
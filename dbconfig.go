@@ -34,18 +34,66 @@ const (
 	DbTypeMysql DbType = iota + 1
 	DbTypePostgres
 	DbTypeCockroachdb
+	DbTypeSqlite
+	DbTypeSqlserver
+	DbTypeOracle
+	DbTypeMariadb
+	DbTypeTidb
+	DbTypeYugabytedb
+	// DbTypePostgresCompatible is any other engine that speaks the PostgreSQL protocol
+	DbTypePostgresCompatible
+	DbTypeMongodb
+	DbTypeRedis
+	DbTypeClickhouse
 )
 
 var DbTypeName = map[DbType]string{
-	DbTypeMysql:       "MYSQL",
-	DbTypePostgres:    "POSTGRESQL",
-	DbTypeCockroachdb: "COCKROACHDB",
+	DbTypeMysql:              "MYSQL",
+	DbTypePostgres:           "POSTGRESQL",
+	DbTypeCockroachdb:        "COCKROACHDB",
+	DbTypeSqlite:             "SQLITE",
+	DbTypeSqlserver:          "SQLSERVER",
+	DbTypeOracle:             "ORACLE",
+	DbTypeMariadb:            "MARIADB",
+	DbTypeTidb:               "TIDB",
+	DbTypeYugabytedb:         "YUGABYTEDB",
+	DbTypePostgresCompatible: "POSTGRES_COMPATIBLE",
+	DbTypeMongodb:            "MONGODB",
+	DbTypeRedis:              "REDIS",
+	DbTypeClickhouse:         "CLICKHOUSE",
 }
 
 var DbTypeValue = map[string]DbType{
-	DbTypeName[DbTypeMysql]:       DbTypeMysql,
-	DbTypeName[DbTypePostgres]:    DbTypePostgres,
-	DbTypeName[DbTypeCockroachdb]: DbTypeCockroachdb,
+	DbTypeName[DbTypeMysql]:              DbTypeMysql,
+	DbTypeName[DbTypePostgres]:           DbTypePostgres,
+	DbTypeName[DbTypeCockroachdb]:        DbTypeCockroachdb,
+	DbTypeName[DbTypeSqlite]:             DbTypeSqlite,
+	DbTypeName[DbTypeSqlserver]:          DbTypeSqlserver,
+	DbTypeName[DbTypeOracle]:             DbTypeOracle,
+	DbTypeName[DbTypeMariadb]:            DbTypeMariadb,
+	DbTypeName[DbTypeTidb]:               DbTypeTidb,
+	DbTypeName[DbTypeYugabytedb]:         DbTypeYugabytedb,
+	DbTypeName[DbTypePostgresCompatible]: DbTypePostgresCompatible,
+	DbTypeName[DbTypeMongodb]:            DbTypeMongodb,
+	DbTypeName[DbTypeRedis]:              DbTypeRedis,
+	DbTypeName[DbTypeClickhouse]:         DbTypeClickhouse,
+}
+
+// DbTypeDefaultPort maps a DbType to its default server port, the embedded databases like SQLite have no port and
+// ClickHouse has the port of the native protocol (see ClickhouseDefaultPort for the other protocol and TLS ports)
+var DbTypeDefaultPort = map[DbType]uint16{
+	DbTypeMysql:              3306,
+	DbTypePostgres:           5432,
+	DbTypeCockroachdb:        26257,
+	DbTypeSqlserver:          1433,
+	DbTypeOracle:             1521,
+	DbTypeMariadb:            3306,
+	DbTypeTidb:               4000,
+	DbTypeYugabytedb:         5433,
+	DbTypePostgresCompatible: 5432,
+	DbTypeMongodb:            27017,
+	DbTypeRedis:              6379,
+	DbTypeClickhouse:         9000,
 }
 
 // String returns the string value of the DbType
@@ -53,6 +101,11 @@ func (s DbType) String() string {
 	return DbTypeName[s]
 }
 
+// DefaultPort returns the default server port of the DbType
+func (s DbType) DefaultPort() uint16 {
+	return DbTypeDefaultPort[s]
+}
+
 // ParseDbType parses the string value to a DbType
 func ParseDbType(value string) (DbType, error) {
 	if s, ok := DbTypeValue[value]; ok {
@@ -86,6 +139,21 @@ func (s *DbType) UnmarshalJSON(data []byte) error {
 	return nil
 }
 
+// MarshalText marshals the enum as text, it is used by the toml encoding
+func (s DbType) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// UnmarshalText unmarshals the text to the enum value
+func (s *DbType) UnmarshalText(data []byte) error {
+	parsed, err := ParseDbType(string(data))
+	if err != nil {
+		return err
+	}
+	*s = parsed
+	return nil
+}
+
 type SSLMode int8
 
 const (
@@ -153,19 +221,51 @@ func (s *SSLMode) UnmarshalJSON(data []byte) error {
 	return nil
 }
 
+// MarshalText marshals the enum as text, it is used by the toml encoding
+func (s SSLMode) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// UnmarshalText unmarshals the text to the enum value
+func (s *SSLMode) UnmarshalText(data []byte) error {
+	parsed, err := ParseSSLMode(string(data))
+	if err != nil {
+		return err
+	}
+	*s = parsed
+	return nil
+}
+
 type SSLConfig struct {
-	Mode SSLMode
-	Cert string
-	Key  string
-	Ca   string
+	Mode SSLMode `json:"mode" toml:"mode"`
+	Cert string  `json:"cert,omitempty" toml:"cert,omitempty"`
+	Key  string  `json:"key,omitempty" toml:"key,omitempty"`
+	Ca   string  `json:"ca,omitempty" toml:"ca,omitempty"`
 }
 
 type Config struct {
-	Type     DbType
-	Host     string
-	Port     uint16
-	User     string
-	Password string
-	Database string
-	SSL      *SSLConfig
+	Type     DbType `json:"type" toml:"type"`
+	Host     string `json:"host" toml:"host"`
+	Port     uint16 `json:"port" toml:"port"`
+	User     string `json:"user" toml:"user"`
+	Password string `json:"password" toml:"password"`
+	// PasswordCommand is a shell command whose trimmed output is used as the password when Password is empty
+	PasswordCommand string     `json:"passwordCommand,omitempty" toml:"passwordCommand,omitempty"`
+	Database        string     `json:"database" toml:"database"`
+	SSL             *SSLConfig `json:"ssl,omitempty" toml:"ssl,omitempty"`
+	// Sqlite holds the SQLite connection options, the database file (or :memory:) is given by Database
+	Sqlite *SqliteConfig `json:"sqlite,omitempty" toml:"sqlite,omitempty"`
+	// Sqlserver holds the SQL Server connection options
+	Sqlserver *SqlserverConfig `json:"sqlserver,omitempty" toml:"sqlserver,omitempty"`
+	// Oracle holds the Oracle connect target, the Database is not used by Oracle
+	Oracle *OracleConfig `json:"oracle,omitempty" toml:"oracle,omitempty"`
+	// Yugabyte holds the load balancing options of the YugabyteDB smart drivers
+	Yugabyte *YugabyteConfig `json:"yugabyte,omitempty" toml:"yugabyte,omitempty"`
+	// Mongodb holds the MongoDB seed list and authentication options, the first host is given by Host and Port
+	Mongodb *MongodbConfig `json:"mongodb,omitempty" toml:"mongodb,omitempty"`
+	// Redis holds the Redis deployment mode, the Database is the Redis database index
+	Redis *RedisConfig `json:"redis,omitempty" toml:"redis,omitempty"`
+	// Clickhouse holds the ClickHouse protocol, compression and the other hosts, the first host is given by Host and
+	// Port
+	Clickhouse *ClickhouseConfig `json:"clickhouse,omitempty" toml:"clickhouse,omitempty"`
 }
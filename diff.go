@@ -0,0 +1,255 @@
+/*
+ * © 2023 fkmatsuda
+
+ * This file is licensed under the terms of the MIT license. Permission is hereby
+ * granted, free of charge, to any person obtaining a copy of this software and
+ * associated documentation files (the "Software"), to deal in the Software without
+ * restriction, including without limitation the rights to use, copy, modify,
+ * merge, publish, and/or distribute copies of the Software, and to permit persons
+ * to whom the Software is furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS," WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY,
+ * WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+ * CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ *
+ */
+
+package dbconfig
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// changedValue replaces the secrets of the field changes
+const changedValue = "changed"
+
+// FieldChange is a difference between two configurations
+type FieldChange struct {
+	// Field is the json path of the value, like host or ssl.mode
+	Field string `json:"field"`
+	// Old is the value of the first configuration, it is empty for the password
+	Old string `json:"old"`
+	// New is the value of the second configuration, it is "changed" for the password
+	New string `json:"new"`
+	// Reconnect is true when the open connections must be replaced to apply the change
+	Reconnect bool `json:"reconnect"`
+}
+
+// String returns the change as "field: old -> new", the password change as "password: changed"
+func (f FieldChange) String() string {
+	if f.Old == "" && f.New == changedValue {
+		return fmt.Sprintf("%s: %s", f.Field, changedValue)
+	}
+	return fmt.Sprintf("%s: %q -> %q", f.Field, f.Old, f.New)
+}
+
+// configField is a value of the configuration
+type configField struct {
+	field string
+	value string
+	// env is the DB_* environment variable of the value
+	env string
+	// omitted values belong to an unset section of the configuration, like ssl when SSL is nil
+	omitted bool
+	// secret values are never shown
+	secret bool
+	// reconnect is true when the value is used to open the connections
+	reconnect bool
+	// credential values identify the client
+	credential bool
+	// file values are the names of files used by the connections
+	file bool
+}
+
+// configFields returns the values of the configuration in a fixed order, the values of the unset sections (like ssl
+// when SSL is nil) are empty
+func configFields(c Config) []configField {
+	ssl := SSLConfig{}
+	if c.SSL != nil {
+		ssl = *c.SSL
+	}
+	sqlite := SqliteConfig{}
+	if c.Sqlite != nil {
+		sqlite = *c.Sqlite
+	}
+	port := ""
+	if c.Port != 0 {
+		port = strconv.Itoa(int(c.Port))
+	}
+	sqlserver := SqlserverConfig{}
+	if c.Sqlserver != nil {
+		sqlserver = *c.Sqlserver
+	}
+	oracle := OracleConfig{}
+	if c.Oracle != nil {
+		oracle = *c.Oracle
+	}
+	yugabyte := YugabyteConfig{}
+	if c.Yugabyte != nil {
+		yugabyte = *c.Yugabyte
+	}
+	mongodb := MongodbConfig{}
+	if c.Mongodb != nil {
+		mongodb = *c.Mongodb
+	}
+	srv := ""
+	if mongodb.SRV {
+		srv = "true"
+	}
+	redis := RedisConfig{}
+	if c.Redis != nil {
+		redis = *c.Redis
+	}
+	clickhouse := ClickhouseConfig{}
+	if c.Clickhouse != nil {
+		clickhouse = *c.Clickhouse
+	}
+	loadBalance := ""
+	if yugabyte.LoadBalance {
+		loadBalance = "true"
+	}
+	busyTimeout := ""
+	if sqlite.BusyTimeout != 0 {
+		busyTimeout = strconv.FormatUint(uint64(sqlite.BusyTimeout), 10)
+	}
+	foreignKeys := ""
+	if sqlite.ForeignKeys {
+		foreignKeys = "true"
+	}
+	return []configField{
+		{field: "type", env: "DB_TYPE", value: c.Type.String(), reconnect: true},
+		{field: "host", env: "DB_HOST", value: c.Host, reconnect: true},
+		{field: "port", env: "DB_PORT", value: port, reconnect: true},
+		{field: "user", env: "DB_USER", value: c.User, reconnect: true, credential: true},
+		{field: "password", env: "DB_PASSWORD", value: c.Password, secret: true, reconnect: true, credential: true},
+		// the command only matters through the password it returns
+		{field: "passwordCommand", env: "DB_PASSWORD_COMMAND", value: c.PasswordCommand},
+		{field: "database", env: "DB_DATABASE", value: c.Database, reconnect: true},
+		{field: "ssl.mode", env: "DB_SSL_MODE", value: ssl.Mode.String(), omitted: c.SSL == nil, reconnect: true},
+		{field: "ssl.ca", env: "DB_SSL_CA", value: ssl.Ca, omitted: c.SSL == nil, reconnect: true, file: true},
+		{
+			field: "ssl.cert", env: "DB_SSL_CERT", value: ssl.Cert, omitted: c.SSL == nil,
+			reconnect: true, credential: true, file: true,
+		},
+		{
+			field: "ssl.key", env: "DB_SSL_KEY", value: ssl.Key, omitted: c.SSL == nil,
+			reconnect: true, credential: true, file: true,
+		},
+		{
+			field: "sqlite.journalMode", env: "DB_SQLITE_JOURNAL_MODE", value: sqlite.JournalMode.String(),
+			omitted: c.Sqlite == nil, reconnect: true,
+		},
+		{
+			field: "sqlite.busyTimeout", env: "DB_SQLITE_BUSY_TIMEOUT", value: busyTimeout,
+			omitted: c.Sqlite == nil, reconnect: true,
+		},
+		{
+			field: "sqlite.foreignKeys", env: "DB_SQLITE_FOREIGN_KEYS", value: foreignKeys,
+			omitted: c.Sqlite == nil, reconnect: true,
+		},
+		{
+			field: "sqlserver.instance", env: "DB_SQLSERVER_INSTANCE", value: sqlserver.Instance,
+			omitted: c.Sqlserver == nil, reconnect: true,
+		},
+		{
+			field: "oracle.serviceName", env: "DB_ORACLE_SERVICE_NAME", value: oracle.ServiceName,
+			omitted: c.Oracle == nil, reconnect: true,
+		},
+		{field: "oracle.sid", env: "DB_ORACLE_SID", value: oracle.SID, omitted: c.Oracle == nil, reconnect: true},
+		{
+			field: "oracle.tnsAlias", env: "DB_ORACLE_TNS_ALIAS", value: oracle.TNSAlias,
+			omitted: c.Oracle == nil, reconnect: true,
+		},
+		{
+			field: "oracle.tnsAdmin", env: "DB_ORACLE_TNS_ADMIN", value: oracle.TNSAdmin,
+			omitted: c.Oracle == nil, reconnect: true,
+		},
+		{
+			field: "yugabyte.loadBalance", env: "DB_YUGABYTE_LOAD_BALANCE", value: loadBalance,
+			omitted: c.Yugabyte == nil, reconnect: true,
+		},
+		{
+			field: "yugabyte.topologyKeys", env: "DB_YUGABYTE_TOPOLOGY_KEYS", value: yugabyte.TopologyKeys,
+			omitted: c.Yugabyte == nil, reconnect: true,
+		},
+		{
+			field: "mongodb.hosts", env: "DB_MONGODB_HOSTS", value: strings.Join(mongodb.Hosts, ","),
+			omitted: c.Mongodb == nil, reconnect: true,
+		},
+		{field: "mongodb.srv", env: "DB_MONGODB_SRV", value: srv, omitted: c.Mongodb == nil, reconnect: true},
+		{
+			field: "mongodb.replicaSet", env: "DB_MONGODB_REPLICA_SET", value: mongodb.ReplicaSet,
+			omitted: c.Mongodb == nil, reconnect: true,
+		},
+		{
+			field: "mongodb.authSource", env: "DB_MONGODB_AUTH_SOURCE", value: mongodb.AuthSource,
+			omitted: c.Mongodb == nil, reconnect: true, credential: true,
+		},
+		{
+			field: "mongodb.authMechanism", env: "DB_MONGODB_AUTH_MECHANISM", value: mongodb.AuthMechanism,
+			omitted: c.Mongodb == nil, reconnect: true, credential: true,
+		},
+		{field: "redis.mode", env: "DB_REDIS_MODE", value: redis.Mode.String(), omitted: c.Redis == nil, reconnect: true},
+		{
+			field: "redis.masterName", env: "DB_REDIS_MASTER_NAME", value: redis.MasterName,
+			omitted: c.Redis == nil, reconnect: true,
+		},
+		{
+			field: "redis.addrs", env: "DB_REDIS_ADDRS", value: strings.Join(redis.Addrs, ","),
+			omitted: c.Redis == nil, reconnect: true,
+		},
+		{
+			field: "clickhouse.protocol", env: "DB_CLICKHOUSE_PROTOCOL", value: clickhouse.Protocol.String(),
+			omitted: c.Clickhouse == nil, reconnect: true,
+		},
+		{
+			field: "clickhouse.compression", env: "DB_CLICKHOUSE_COMPRESSION", value: clickhouse.Compression,
+			omitted: c.Clickhouse == nil, reconnect: true,
+		},
+		{
+			field: "clickhouse.hosts", env: "DB_CLICKHOUSE_HOSTS", value: strings.Join(clickhouse.Hosts, ","),
+			omitted: c.Clickhouse == nil, reconnect: true,
+		},
+	}
+}
+
+// Diff returns the changes from the configuration a to the configuration b, the passwords are never included in the
+// changes, a password change has an empty old value and the "changed" new value
+func Diff(a Config, b Config) []FieldChange {
+	var changes []FieldChange
+	fieldsA, fieldsB := configFields(a), configFields(b)
+	for i, fieldA := range fieldsA {
+		fieldB := fieldsB[i]
+		if fieldA.value == fieldB.value {
+			continue
+		}
+		change := FieldChange{Field: fieldA.field, Old: fieldA.value, New: fieldB.value, Reconnect: fieldA.reconnect}
+		if fieldA.secret {
+			change.Old, change.New = "", changedValue
+		}
+		changes = append(changes, change)
+	}
+	return changes
+}
+
+// DiffSources loads the configurations from the sources of LoadSource and returns their changes like Diff, the
+// password commands are not run so the configurations can be compared without their secrets
+func DiffSources(a string, b string) ([]FieldChange, error) {
+	configA, err := loadSource(a, false)
+	if err != nil {
+		return nil, err
+	}
+	configB, err := loadSource(b, false)
+	if err != nil {
+		return nil, err
+	}
+	return Diff(configA, configB), nil
+}
@@ -0,0 +1,89 @@
+/*
+ * © 2023 fkmatsuda
+
+ * This file is licensed under the terms of the MIT license. Permission is hereby
+ * granted, free of charge, to any person obtaining a copy of this software and
+ * associated documentation files (the "Software"), to deal in the Software without
+ * restriction, including without limitation the rights to use, copy, modify,
+ * merge, publish, and/or distribute copies of the Software, and to permit persons
+ * to whom the Software is furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS," WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY,
+ * WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+ * CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ *
+ */
+
+package dbconfig
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+
+	old := Config{
+		Type:     DbTypePostgres,
+		Host:     "db.example.com",
+		Port:     5432,
+		User:     "app",
+		Password: "old-password",
+		Database: "orders",
+	}
+
+	// Test equal configurations
+	t.Run("Test equal configurations", func(t *testing.T) {
+		if changes := Diff(old, old); len(changes) != 0 {
+			t.Errorf("No changes expected, got %v", changes)
+			return
+		}
+	})
+
+	// Test changed configurations
+	t.Run("Test changed configurations", func(t *testing.T) {
+		changed := old
+		changed.Host = "replica.example.com"
+		changed.Password = "new-password"
+		changed.PasswordCommand = "pass show db/app"
+		changed.SSL = &SSLConfig{Mode: SSLModeVerifyCA, Ca: "ca.crt"}
+
+		expected := []FieldChange{
+			{Field: "host", Old: "db.example.com", New: "replica.example.com", Reconnect: true},
+			{Field: "password", Old: "", New: "changed", Reconnect: true},
+			{Field: "passwordCommand", Old: "", New: "pass show db/app", Reconnect: false},
+			{Field: "ssl.mode", Old: "", New: "verify-ca", Reconnect: true},
+			{Field: "ssl.ca", Old: "", New: "ca.crt", Reconnect: true},
+		}
+		changes := Diff(old, changed)
+		if !reflect.DeepEqual(changes, expected) {
+			t.Errorf("The changes %v expected, got %v", expected, changes)
+			return
+		}
+		if changes[0].String() != `host: "db.example.com" -> "replica.example.com"` ||
+			changes[1].String() != "password: changed" {
+			t.Errorf("Unexpected change descriptions %s and %s", changes[0], changes[1])
+			return
+		}
+	})
+
+	// Test diff sources
+	t.Run("Test diff sources", func(t *testing.T) {
+		changes, err := DiffSources("postgres://app:a@db/orders", "postgres://app:a@db:6432/orders")
+		if err != nil {
+			t.Errorf("Error comparing sources: %s", err.Error())
+			return
+		}
+		if len(changes) != 1 || changes[0].Field != "port" || changes[0].New != "6432" {
+			t.Errorf("The port change expected, got %v", changes)
+			return
+		}
+	})
+
+}
@@ -0,0 +1,282 @@
+/*
+ * © 2023 fkmatsuda
+
+ * This file is licensed under the terms of the MIT license. Permission is hereby
+ * granted, free of charge, to any person obtaining a copy of this software and
+ * associated documentation files (the "Software"), to deal in the Software without
+ * restriction, including without limitation the rights to use, copy, modify,
+ * merge, publish, and/or distribute copies of the Software, and to permit persons
+ * to whom the Software is furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS," WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY,
+ * WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+ * CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ *
+ */
+
+package dbconfig
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/fkmatsuda-dev/commons/errorex"
+	"github.com/fkmatsuda-dev/commons/files"
+)
+
+const (
+	// encryptedPrefix and encryptedSuffix delimit the encrypted values, the format is shared with SOPS:
+	// ENC[AES256_GCM,data:<base64>,iv:<base64>,tag:<base64>,type:<type>]
+	encryptedPrefix = "ENC["
+	encryptedSuffix = "]"
+	encryptedCipher = "AES256_GCM"
+	// encryptionKeySize is the size in bytes of the AES-256 keys
+	encryptionKeySize = 32
+	// encryptionNonceSize is the size in bytes of the GCM nonces, SOPS uses 32 bytes nonces
+	encryptionNonceSize = 32
+
+	encryptionKeyNotLoaded = "Encryption key not loaded"
+	encryptionError        = "Value encryption error"
+	decryptionError        = "Value decryption error"
+)
+
+// IsEncrypted checks if the value is an encrypted value
+func IsEncrypted(value string) bool {
+	return strings.HasPrefix(value, encryptedPrefix+encryptedCipher+",") && strings.HasSuffix(value, encryptedSuffix)
+}
+
+// GenerateKey generates a random encryption key, the key is returned base64 encoded as expected by DBCONFIG_KEY
+func GenerateKey() (string, error) {
+	key := make([]byte, encryptionKeySize)
+	if _, err := rand.Read(key); err != nil {
+		return "", errorex.New(ErrorCodeEncryptionError, encryptionError, err.Error())
+	}
+	return base64.StdEncoding.EncodeToString(key), nil
+}
+
+// ParseKey decodes a base64 encoded encryption key
+func ParseKey(encoded string) ([]byte, error) {
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+	if err != nil {
+		return nil, errorex.New(ErrorCodeEncryptionKeyNotLoaded, encryptionKeyNotLoaded, err.Error())
+	}
+	if len(key) != encryptionKeySize {
+		return nil, errorex.New(
+			ErrorCodeEncryptionKeyNotLoaded,
+			encryptionKeyNotLoaded,
+			fmt.Sprintf("the encryption key must have %d bytes, got %d", encryptionKeySize, len(key)),
+		)
+	}
+	return key, nil
+}
+
+// LoadKey loads the encryption key from the DBCONFIG_KEY environment variable or from the file named by the
+// DBCONFIG_KEY_FILE environment variable, both hold the base64 encoded key
+func LoadKey() ([]byte, error) {
+	if encoded, chk := lookupEnv("DBCONFIG_KEY"); chk {
+		return ParseKey(encoded)
+	}
+	if keyFile, chk := lookupEnv("DBCONFIG_KEY_FILE"); chk {
+		encoded, err := files.ReadFile(keyFile)
+		if err != nil {
+			return nil, errorex.New(ErrorCodeEncryptionKeyNotLoaded, encryptionKeyNotLoaded, err.Error())
+		}
+		return ParseKey(encoded)
+	}
+	return nil, errorex.New(
+		ErrorCodeEncryptionKeyNotLoaded,
+		encryptionKeyNotLoaded,
+		"DBCONFIG_KEY or DBCONFIG_KEY_FILE environment variable not found",
+	)
+}
+
+// Encrypt encrypts the value with the key and returns it in the ENC[AES256_GCM,...] format, the value is not bound to
+// the field that holds it, so the encrypted values of the same key can be moved between fields
+func Encrypt(value string, key []byte) (string, error) {
+	return encryptValue(value, "str", key, nil)
+}
+
+// Decrypt decrypts a value in the ENC[AES256_GCM,...] format with the key
+func Decrypt(value string, key []byte) (string, error) {
+	plaintext, _, err := decryptValue(value, key, nil)
+	return plaintext, err
+}
+
+// encryptValue encrypts the value with AES-256-GCM, the additional data is authenticated with the value, the SOPS
+// files use the value path as additional data and the ENC[...] values of Encrypt have none
+func encryptValue(value string, valueType string, key []byte, additionalData []byte) (string, error) {
+	aead, err := newAEAD(key)
+	if err != nil {
+		return "", errorex.New(ErrorCodeEncryptionError, encryptionError, err.Error())
+	}
+	nonce := make([]byte, encryptionNonceSize)
+	if _, err := rand.Read(nonce); err != nil {
+		return "", errorex.New(ErrorCodeEncryptionError, encryptionError, err.Error())
+	}
+	sealed := aead.Seal(nil, nonce, []byte(value), additionalData)
+	data, tag := sealed[:len(sealed)-aead.Overhead()], sealed[len(sealed)-aead.Overhead():]
+	return fmt.Sprintf(
+		"%s%s,data:%s,iv:%s,tag:%s,type:%s%s",
+		encryptedPrefix,
+		encryptedCipher,
+		base64.StdEncoding.EncodeToString(data),
+		base64.StdEncoding.EncodeToString(nonce),
+		base64.StdEncoding.EncodeToString(tag),
+		valueType,
+		encryptedSuffix,
+	), nil
+}
+
+// decryptValue decrypts a value in the ENC[AES256_GCM,...] format and returns the plaintext and its type
+func decryptValue(value string, key []byte, additionalData []byte) (string, string, error) {
+	if !IsEncrypted(value) {
+		return "", "", errorex.New(
+			ErrorCodeDecryptionError,
+			decryptionError,
+			"the value is not in the ENC[AES256_GCM,...] format",
+		)
+	}
+	// parse the value fields
+	fields := make(map[string][]byte)
+	var valueType string
+	content := strings.TrimSuffix(strings.TrimPrefix(value, encryptedPrefix+encryptedCipher+","), encryptedSuffix)
+	for _, field := range strings.Split(content, ",") {
+		name, fieldValue, ok := strings.Cut(field, ":")
+		if !ok {
+			return "", "", errorex.New(ErrorCodeDecryptionError, decryptionError, fmt.Sprintf("invalid field \"%s\"", field))
+		}
+		if name == "type" {
+			valueType = fieldValue
+			continue
+		}
+		decoded, err := base64.StdEncoding.DecodeString(fieldValue)
+		if err != nil {
+			return "", "", errorex.New(
+				ErrorCodeDecryptionError,
+				decryptionError,
+				fmt.Sprintf("invalid %s field: %s", name, err.Error()),
+			)
+		}
+		fields[name] = decoded
+	}
+	for _, name := range []string{"data", "iv", "tag"} {
+		if _, ok := fields[name]; !ok {
+			return "", "", errorex.New(ErrorCodeDecryptionError, decryptionError, fmt.Sprintf("%s field not found", name))
+		}
+	}
+
+	aead, err := newAEADWithNonceSize(key, len(fields["iv"]))
+	if err != nil {
+		return "", "", errorex.New(ErrorCodeDecryptionError, decryptionError, err.Error())
+	}
+	plaintext, err := aead.Open(nil, fields["iv"], append(fields["data"], fields["tag"]...), additionalData)
+	if err != nil {
+		return "", "", errorex.New(
+			ErrorCodeDecryptionError,
+			decryptionError,
+			"the value cannot be authenticated with the key",
+		)
+	}
+	return string(plaintext), valueType, nil
+}
+
+// decryptTree decrypts every encrypted string of a decoded JSON document, numbers and booleans are restored from
+// the value type
+func decryptTree(node interface{}, key []byte) (interface{}, error) {
+	switch v := node.(type) {
+	case map[string]interface{}:
+		for name, child := range v {
+			decrypted, err := decryptTree(child, key)
+			if err != nil {
+				return nil, err
+			}
+			v[name] = decrypted
+		}
+	case []interface{}:
+		for i, child := range v {
+			decrypted, err := decryptTree(child, key)
+			if err != nil {
+				return nil, err
+			}
+			v[i] = decrypted
+		}
+	case string:
+		if !IsEncrypted(v) {
+			return v, nil
+		}
+		plaintext, valueType, err := decryptValue(v, key, nil)
+		if err != nil {
+			return nil, err
+		}
+		return typedValue(plaintext, valueType), nil
+	}
+	return node, nil
+}
+
+// typedValue converts a decrypted plaintext to the JSON value of its type
+func typedValue(plaintext string, valueType string) interface{} {
+	switch valueType {
+	case "int", "float":
+		return json.Number(plaintext)
+	case "bool":
+		return strings.EqualFold(plaintext, "true")
+	}
+	return plaintext
+}
+
+// decryptDocument decrypts the encrypted values of a decoded configuration file, the encryption key is only loaded
+// when the document has encrypted values
+func decryptDocument(document interface{}) (interface{}, error) {
+	if !hasEncryptedValues(document) {
+		return document, nil
+	}
+	key, err := LoadKey()
+	if err != nil {
+		return nil, err
+	}
+	return decryptTree(document, key)
+}
+
+// hasEncryptedValues checks if a decoded JSON document has encrypted strings
+func hasEncryptedValues(node interface{}) bool {
+	switch v := node.(type) {
+	case map[string]interface{}:
+		for _, child := range v {
+			if hasEncryptedValues(child) {
+				return true
+			}
+		}
+	case []interface{}:
+		for _, child := range v {
+			if hasEncryptedValues(child) {
+				return true
+			}
+		}
+	case string:
+		return IsEncrypted(v)
+	}
+	return false
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	return newAEADWithNonceSize(key, encryptionNonceSize)
+}
+
+func newAEADWithNonceSize(key []byte, nonceSize int) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCMWithNonceSize(block, nonceSize)
+}
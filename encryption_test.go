@@ -0,0 +1,164 @@
+/*
+ * © 2023 fkmatsuda
+
+ * This file is licensed under the terms of the MIT license. Permission is hereby
+ * granted, free of charge, to any person obtaining a copy of this software and
+ * associated documentation files (the "Software"), to deal in the Software without
+ * restriction, including without limitation the rights to use, copy, modify,
+ * merge, publish, and/or distribute copies of the Software, and to permit persons
+ * to whom the Software is furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS," WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY,
+ * WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+ * CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ *
+ */
+
+package dbconfig
+
+import (
+	"testing"
+
+	"github.com/fkmatsuda-dev/commons/errorex"
+	"github.com/fkmatsuda-dev/commons/files"
+)
+
+func TestEncryption(t *testing.T) {
+
+	// Create a temporary directory inside system temp directory
+	dirName, err := files.CreateTempDir()
+	if err != nil {
+		t.Errorf("Error creating temporary directory: %s", err.Error())
+		return
+	}
+	defer func() {
+		_ = files.CleanupTempDirs()
+	}()
+
+	encodedKey, err := GenerateKey()
+	if err != nil {
+		t.Errorf("Error generating key: %s", err.Error())
+		return
+	}
+	key, err := ParseKey(encodedKey)
+	if err != nil {
+		t.Errorf("Error parsing key: %s", err.Error())
+		return
+	}
+
+	// Test encrypt and decrypt
+	t.Run("Test encrypt and decrypt", func(t *testing.T) {
+		encrypted, err := Encrypt("s3cr3t", key)
+		if err != nil {
+			t.Errorf("Error encrypting value: %s", err.Error())
+			return
+		}
+		if !IsEncrypted(encrypted) {
+			t.Errorf("The encrypted value must be in the ENC[AES256_GCM,...] format: %s", encrypted)
+			return
+		}
+		decrypted, err := Decrypt(encrypted, key)
+		if err != nil {
+			t.Errorf("Error decrypting value: %s", err.Error())
+			return
+		}
+		if decrypted != "s3cr3t" {
+			t.Errorf("The decrypted value is different from the original value")
+			return
+		}
+	})
+
+	// Test decrypt with wrong key
+	t.Run("Test decrypt with wrong key", func(t *testing.T) {
+		encrypted, err := Encrypt("s3cr3t", key)
+		if err != nil {
+			t.Errorf("Error encrypting value: %s", err.Error())
+			return
+		}
+		otherKey, _ := GenerateKey()
+		wrongKey, _ := ParseKey(otherKey)
+		_, err = Decrypt(encrypted, wrongKey)
+		if !errorex.IS(err, ErrorCodeDecryptionError) {
+			t.Errorf("Decryption error expected")
+			return
+		}
+	})
+
+	// Test parse invalid key
+	t.Run("Test parse invalid key", func(t *testing.T) {
+		_, err := ParseKey("c2hvcnQ=")
+		if !errorex.IS(err, ErrorCodeEncryptionKeyNotLoaded) {
+			t.Errorf("Encryption key not loaded error expected")
+			return
+		}
+	})
+
+	encryptedPassword, err := Encrypt("s3cr3t", key)
+	if err != nil {
+		t.Errorf("Error encrypting value: %s", err.Error())
+		return
+	}
+	err = files.WriteFile(dirName+"/dbconfig.json", `{
+		"type": "POSTGRESQL",
+		"host": "localhost",
+		"port": 5432,
+		"user": "postgres",
+		"password": "`+encryptedPassword+`",
+		"database": "postgres"
+	}`)
+	if err != nil {
+		t.Errorf("Error writing json configuration file: %s", err.Error())
+		return
+	}
+
+	// Test configuration file with DBCONFIG_KEY
+	t.Run("Test configuration file with DBCONFIG_KEY", func(t *testing.T) {
+		t.Setenv("DBCONFIG_KEY", encodedKey)
+
+		config, err := LoadConfig(dirName)
+		if err != nil {
+			t.Errorf("Error loading configuration: %s", err.Error())
+			return
+		}
+		if config.Password != "s3cr3t" {
+			t.Errorf("The password must be decrypted")
+			return
+		}
+	})
+
+	// Test configuration file with DBCONFIG_KEY_FILE
+	t.Run("Test configuration file with DBCONFIG_KEY_FILE", func(t *testing.T) {
+		keyFile := dirName + "/dbconfig.key"
+		if err := files.WriteFile(keyFile, encodedKey+"\n"); err != nil {
+			t.Errorf("Error writing key file: %s", err.Error())
+			return
+		}
+		t.Setenv("DBCONFIG_KEY_FILE", keyFile)
+
+		config, err := LoadConfig(dirName)
+		if err != nil {
+			t.Errorf("Error loading configuration: %s", err.Error())
+			return
+		}
+		if config.Password != "s3cr3t" {
+			t.Errorf("The password must be decrypted")
+			return
+		}
+	})
+
+	// Test configuration file without key
+	t.Run("Test configuration file without key", func(t *testing.T) {
+		_, err := LoadConfig(dirName)
+		if !errorex.IS(err, ErrorCodeEncryptionKeyNotLoaded) {
+			t.Errorf("Encryption key not loaded error expected")
+			return
+		}
+	})
+
+}
@@ -0,0 +1,136 @@
+/*
+ * © 2023 fkmatsuda
+
+ * This file is licensed under the terms of the MIT license. Permission is hereby
+ * granted, free of charge, to any person obtaining a copy of this software and
+ * associated documentation files (the "Software"), to deal in the Software without
+ * restriction, including without limitation the rights to use, copy, modify,
+ * merge, publish, and/or distribute copies of the Software, and to permit persons
+ * to whom the Software is furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS," WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY,
+ * WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+ * CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ *
+ */
+
+package dbconfig
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/fkmatsuda-dev/commons/errorex"
+	"github.com/fkmatsuda-dev/commons/files"
+)
+
+const envFileParseError = "Environment file parse error"
+
+// LoadEnvFile loads the database settings from the DB_* variables of a .env file like LoadFromEnv does, the lines
+// have the NAME=value format with an optional export prefix, and the values can be single or double quoted
+func LoadEnvFile(file string) (Config, error) {
+	return loadEnvFile(file, true)
+}
+
+func loadEnvFile(file string, resolvePassword bool) (Config, error) {
+	content, err := files.ReadFile(file)
+	if err != nil {
+		return Config{}, errorex.New(ErrorCodeConfigFileNotLoaded, "Configuration file not loaded", err.Error())
+	}
+	vars, err := parseEnvFile(file, content)
+	if err != nil {
+		return Config{}, err
+	}
+	return loadEnvConfig(func(name string) (string, bool) {
+		value, ok := vars[name]
+		return value, ok && value != ""
+	}, resolvePassword)
+}
+
+// parseEnvFile parses the variables of the .env file content
+func parseEnvFile(file string, content string) (map[string]string, error) {
+	vars := make(map[string]string)
+	for lineNumber, line := range strings.Split(content, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimPrefix(line, "export ")
+		name, value, ok := strings.Cut(line, "=")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" || strings.ContainsAny(name, " \t") {
+			return nil, envFileSyntaxError(file, lineNumber+1)
+		}
+		value, err := parseEnvValue(strings.TrimSpace(value))
+		if err != nil {
+			return nil, envFileSyntaxError(file, lineNumber+1)
+		}
+		vars[name] = value
+	}
+	return vars, nil
+}
+
+// parseEnvValue unquotes the value following the shell rules, the single quoted parts are kept as they are, the
+// double quoted parts accept the backslash escapes and the unquoted parts end at a # comment
+func parseEnvValue(value string) (string, error) {
+	var result strings.Builder
+	// the unquoted blanks are only written when they are followed by other characters
+	var blanks strings.Builder
+	write := func(text string) {
+		result.WriteString(blanks.String())
+		blanks.Reset()
+		result.WriteString(text)
+	}
+	for i := 0; i < len(value); i++ {
+		switch c := value[i]; c {
+		case '\'':
+			end := strings.IndexByte(value[i+1:], '\'')
+			if end < 0 {
+				return "", fmt.Errorf("unterminated single quote")
+			}
+			write(value[i+1 : i+1+end])
+			i += end + 1
+		case '"':
+			var quoted strings.Builder
+			for i++; i < len(value) && value[i] != '"'; i++ {
+				if value[i] == '\\' && i+1 < len(value) {
+					i++
+					if value[i] == 'n' {
+						quoted.WriteByte('\n')
+						continue
+					}
+				}
+				quoted.WriteByte(value[i])
+			}
+			if i >= len(value) {
+				return "", fmt.Errorf("unterminated double quote")
+			}
+			write(quoted.String())
+		case ' ', '\t':
+			blanks.WriteByte(c)
+		case '#':
+			if blanks.Len() > 0 {
+				return result.String(), nil
+			}
+			write(string(c))
+		default:
+			write(string(c))
+		}
+	}
+	return result.String(), nil
+}
+
+func envFileSyntaxError(file string, lineNumber int) error {
+	return errorex.New(
+		ErrorCodeEnvFileParseError,
+		envFileParseError,
+		fmt.Sprintf("syntax error in environment file \"%s\", line %d", filepath.Base(file), lineNumber),
+	)
+}
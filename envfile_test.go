@@ -0,0 +1,118 @@
+/*
+ * © 2023 fkmatsuda
+
+ * This file is licensed under the terms of the MIT license. Permission is hereby
+ * granted, free of charge, to any person obtaining a copy of this software and
+ * associated documentation files (the "Software"), to deal in the Software without
+ * restriction, including without limitation the rights to use, copy, modify,
+ * merge, publish, and/or distribute copies of the Software, and to permit persons
+ * to whom the Software is furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS," WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY,
+ * WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+ * CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ *
+ */
+
+package dbconfig
+
+import (
+	"testing"
+
+	"github.com/fkmatsuda-dev/commons/errorex"
+	"github.com/fkmatsuda-dev/commons/files"
+)
+
+func TestEnvFile(t *testing.T) {
+
+	// Create a temporary directory inside system temp directory
+	dirName, err := files.CreateTempDir()
+	if err != nil {
+		t.Errorf("Error creating temporary directory: %s", err.Error())
+		return
+	}
+	defer func() {
+		_ = files.CleanupTempDirs()
+	}()
+
+	// Test parse values
+	t.Run("Test parse values", func(t *testing.T) {
+		values := map[string]string{
+			`plain`:                  "plain",
+			`with spaces  # comment`: "with spaces",
+			`pass#word`:              "pass#word",
+			`'single "quoted" $x'`:   `single "quoted" $x`,
+			`"double \"quoted\"\n"`:  "double \"quoted\"\n",
+			`'it'"'"'s'`:             "it's",
+			`''`:                     "",
+		}
+		for value, expected := range values {
+			parsed, err := parseEnvValue(value)
+			if err != nil {
+				t.Errorf("Error parsing %s: %s", value, err.Error())
+				continue
+			}
+			if parsed != expected {
+				t.Errorf("The value %q expected for %s, got %q", expected, value, parsed)
+			}
+		}
+	})
+
+	// Test load environment file
+	t.Run("Test load environment file", func(t *testing.T) {
+		envFile := dirName + "/app.env"
+		err := files.WriteFile(envFile, `# database settings
+export DB_TYPE=POSTGRESQL
+DB_HOST=db.example.com
+DB_PORT="6432"
+DB_USER=app
+DB_PASSWORD='s3cr3t #1'
+DB_DATABASE=orders
+DB_SSL_MODE=require
+`)
+		if err != nil {
+			t.Errorf("Error writing environment file: %s", err.Error())
+			return
+		}
+		t.Setenv("DB_HOST", "ignored.example.com")
+
+		config, err := LoadEnvFile(envFile)
+		if err != nil {
+			t.Errorf("Error loading environment file: %s", err.Error())
+			return
+		}
+		expected := Config{
+			Type:     DbTypePostgres,
+			Host:     "db.example.com",
+			Port:     6432,
+			User:     "app",
+			Password: "s3cr3t #1",
+			Database: "orders",
+			SSL:      &SSLConfig{Mode: SSLModeRequire},
+		}
+		if !expected.compare(config) {
+			t.Errorf("The configuration %+v expected, got %+v", expected, config)
+			return
+		}
+	})
+
+	// Test invalid environment file
+	t.Run("Test invalid environment file", func(t *testing.T) {
+		envFile := dirName + "/invalid.env"
+		if err := files.WriteFile(envFile, "DB_TYPE=MYSQL\nDB_PASSWORD='unterminated\n"); err != nil {
+			t.Errorf("Error writing environment file: %s", err.Error())
+			return
+		}
+		if _, err := LoadEnvFile(envFile); !errorex.IS(err, ErrorCodeEnvFileParseError) {
+			t.Errorf("Environment file parse error expected")
+			return
+		}
+	})
+
+}
@@ -25,13 +25,51 @@ package dbconfig
 import "github.com/fkmatsuda-dev/commons/errorex"
 
 const (
-	ErrorCodeDbTypeParseError     = "DBCONFIG-1001"
-	ErrorCodeSSLModeParseError    = "DBCONFIG-1005"
-	ErrorCodeConfigFileNotFound   = "DBCONFIG-1011"
-	ErrorCodeConfigFileNotLoaded  = "DBCONFIG-1012"
-	ErrorCodeEnvConfigNotLoaded   = "DBCONFIG-1013"
-	ErrorCodeConfigFileParseError = "DBCONFIG-1014"
-	ErrorCodeEnvConfigParseError  = "DBCONFIG-1015"
+	ErrorCodeDbTypeParseError             = "DBCONFIG-1001"
+	ErrorCodeSSLModeParseError            = "DBCONFIG-1005"
+	ErrorCodeConfigFileNotFound           = "DBCONFIG-1011"
+	ErrorCodeConfigFileNotLoaded          = "DBCONFIG-1012"
+	ErrorCodeEnvConfigNotLoaded           = "DBCONFIG-1013"
+	ErrorCodeConfigFileParseError         = "DBCONFIG-1014"
+	ErrorCodeEnvConfigParseError          = "DBCONFIG-1015"
+	ErrorCodeSecretParseError             = "DBCONFIG-1016"
+	ErrorCodeServiceBindingNotFound       = "DBCONFIG-1017"
+	ErrorCodeServiceBindingParseError     = "DBCONFIG-1018"
+	ErrorCodePasswordCommandFailed        = "DBCONFIG-1019"
+	ErrorCodePasswordCommandTimeout       = "DBCONFIG-1020"
+	ErrorCodeEncryptionKeyNotLoaded       = "DBCONFIG-1021"
+	ErrorCodeEncryptionError              = "DBCONFIG-1022"
+	ErrorCodeDecryptionError              = "DBCONFIG-1023"
+	ErrorCodeSopsKeyNotLoaded             = "DBCONFIG-1024"
+	ErrorCodeSopsMacMismatch              = "DBCONFIG-1025"
+	ErrorCodeSopsMetadataError            = "DBCONFIG-1026"
+	ErrorCodeConfigSignatureNotFound      = "DBCONFIG-1027"
+	ErrorCodeConfigSignatureInvalid       = "DBCONFIG-1028"
+	ErrorCodeTrustedKeysParseError        = "DBCONFIG-1029"
+	ErrorCodePermissionCheckParseError    = "DBCONFIG-1030"
+	ErrorCodeInsecureConfigFile           = "DBCONFIG-1031"
+	ErrorCodeInsecureKeyFile              = "DBCONFIG-1032"
+	ErrorCodeInsecureCertFile             = "DBCONFIG-1033"
+	ErrorCodeInsecureFileOwner            = "DBCONFIG-1034"
+	ErrorCodeConfigInvalid                = "DBCONFIG-1035"
+	ErrorCodePgServiceNotFound            = "DBCONFIG-1036"
+	ErrorCodePgServiceParseError          = "DBCONFIG-1037"
+	ErrorCodeInsecurePgpassFile           = "DBCONFIG-1038"
+	ErrorCodePgpassWriteError             = "DBCONFIG-1039"
+	ErrorCodeMysqlOptionFileNotFound      = "DBCONFIG-1040"
+	ErrorCodeMysqlOptionFileParseError    = "DBCONFIG-1041"
+	ErrorCodeMysqlOptionFileWriteError    = "DBCONFIG-1042"
+	ErrorCodeDSNError                     = "DBCONFIG-1043"
+	ErrorCodeConfigFormatError            = "DBCONFIG-1044"
+	ErrorCodeConfigFileWriteError         = "DBCONFIG-1045"
+	ErrorCodeURLParseError                = "DBCONFIG-1046"
+	ErrorCodeEnvFileParseError            = "DBCONFIG-1047"
+	ErrorCodeSqliteJournalModeParseError  = "DBCONFIG-1048"
+	ErrorCodeOracleTNSAliasNotResolved    = "DBCONFIG-1049"
+	ErrorCodeEasyConnectParseError        = "DBCONFIG-1050"
+	ErrorCodeRedisModeParseError          = "DBCONFIG-1051"
+	ErrorCodeTLSConfigError               = "DBCONFIG-1052"
+	ErrorCodeClickhouseProtocolParseError = "DBCONFIG-1053"
 )
 
 func init() {
@@ -43,4 +81,42 @@ func init() {
 	errorex.RegisterErrorCode(ErrorCodeEnvConfigNotLoaded, "Environment configuration not loaded")
 	errorex.RegisterErrorCode(ErrorCodeEnvConfigParseError, "Environment configuration cannot be parsed")
 	errorex.RegisterErrorCode(ErrorCodeConfigFileParseError, "Configuration file parse error")
+	errorex.RegisterErrorCode(ErrorCodeSecretParseError, "Secret payload parse error")
+	errorex.RegisterErrorCode(ErrorCodeServiceBindingNotFound, "Service binding not found")
+	errorex.RegisterErrorCode(ErrorCodeServiceBindingParseError, "Service binding parse error")
+	errorex.RegisterErrorCode(ErrorCodePasswordCommandFailed, "Password command failed")
+	errorex.RegisterErrorCode(ErrorCodePasswordCommandTimeout, "Password command timed out")
+	errorex.RegisterErrorCode(ErrorCodeEncryptionKeyNotLoaded, "Encryption key not loaded")
+	errorex.RegisterErrorCode(ErrorCodeEncryptionError, "Value encryption error")
+	errorex.RegisterErrorCode(ErrorCodeDecryptionError, "Value decryption error")
+	errorex.RegisterErrorCode(ErrorCodeSopsKeyNotLoaded, "SOPS data key not loaded")
+	errorex.RegisterErrorCode(ErrorCodeSopsMacMismatch, "SOPS MAC mismatch")
+	errorex.RegisterErrorCode(ErrorCodeSopsMetadataError, "SOPS metadata error")
+	errorex.RegisterErrorCode(ErrorCodeConfigSignatureNotFound, "Configuration file signature not found")
+	errorex.RegisterErrorCode(ErrorCodeConfigSignatureInvalid, "Configuration file signature invalid")
+	errorex.RegisterErrorCode(ErrorCodeTrustedKeysParseError, "Trusted keys parse error")
+	errorex.RegisterErrorCode(ErrorCodePermissionCheckParseError, "PermissionCheck parse error")
+	errorex.RegisterErrorCode(ErrorCodeInsecureConfigFile, "Insecure configuration file permissions")
+	errorex.RegisterErrorCode(ErrorCodeInsecureKeyFile, "Insecure SSL key file permissions")
+	errorex.RegisterErrorCode(ErrorCodeInsecureCertFile, "Insecure SSL certificate file permissions")
+	errorex.RegisterErrorCode(ErrorCodeInsecureFileOwner, "Insecure file ownership")
+	errorex.RegisterErrorCode(ErrorCodeConfigInvalid, "Configuration invalid")
+	errorex.RegisterErrorCode(ErrorCodePgServiceNotFound, "PostgreSQL service not found")
+	errorex.RegisterErrorCode(ErrorCodePgServiceParseError, "PostgreSQL service file parse error")
+	errorex.RegisterErrorCode(ErrorCodeInsecurePgpassFile, "Insecure password file permissions")
+	errorex.RegisterErrorCode(ErrorCodePgpassWriteError, "Password file write error")
+	errorex.RegisterErrorCode(ErrorCodeMysqlOptionFileNotFound, "MySQL option file not found")
+	errorex.RegisterErrorCode(ErrorCodeMysqlOptionFileParseError, "MySQL option file parse error")
+	errorex.RegisterErrorCode(ErrorCodeMysqlOptionFileWriteError, "MySQL option file write error")
+	errorex.RegisterErrorCode(ErrorCodeDSNError, "DSN build error")
+	errorex.RegisterErrorCode(ErrorCodeConfigFormatError, "Configuration format not supported")
+	errorex.RegisterErrorCode(ErrorCodeConfigFileWriteError, "Configuration file write error")
+	errorex.RegisterErrorCode(ErrorCodeURLParseError, "Database URL parse error")
+	errorex.RegisterErrorCode(ErrorCodeEnvFileParseError, "Environment file parse error")
+	errorex.RegisterErrorCode(ErrorCodeSqliteJournalModeParseError, "SqliteJournalMode parse error")
+	errorex.RegisterErrorCode(ErrorCodeOracleTNSAliasNotResolved, "Oracle TNS alias not resolved")
+	errorex.RegisterErrorCode(ErrorCodeEasyConnectParseError, "Oracle Easy Connect parse error")
+	errorex.RegisterErrorCode(ErrorCodeRedisModeParseError, "RedisMode parse error")
+	errorex.RegisterErrorCode(ErrorCodeTLSConfigError, "TLS configuration error")
+	errorex.RegisterErrorCode(ErrorCodeClickhouseProtocolParseError, "ClickhouseProtocol parse error")
 }
@@ -0,0 +1,202 @@
+/*
+ * © 2023 fkmatsuda
+
+ * This file is licensed under the terms of the MIT license. Permission is hereby
+ * granted, free of charge, to any person obtaining a copy of this software and
+ * associated documentation files (the "Software"), to deal in the Software without
+ * restriction, including without limitation the rights to use, copy, modify,
+ * merge, publish, and/or distribute copies of the Software, and to permit persons
+ * to whom the Software is furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS," WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY,
+ * WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+ * CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ *
+ */
+
+package dbconfig
+
+import (
+	"strings"
+
+	"github.com/fkmatsuda-dev/commons/errorex"
+	"github.com/fkmatsuda-dev/commons/files"
+	"github.com/fkmatsuda-dev/env"
+)
+
+// ValueSource describes where a value of the configuration was loaded from
+type ValueSource struct {
+	// Field is the json path of the value, like host or ssl.mode
+	Field string
+	// Value is the loaded value, the password is redacted
+	Value string
+	// Source describes where the value came from, like "DB_HOST environment variable" or "file dbconfig.json"
+	Source string
+}
+
+const (
+	sourceDefault         = "default"
+	sourceNotSet          = "not set"
+	sourcePasswordCommand = "password command output"
+)
+
+// Explain loads the configuration like LoadConfig and returns where each of its values came from
+func Explain(path string) (Config, []ValueSource, error) {
+	configFile, err := searchConfigFile(path)
+	if err != nil {
+		if !errorex.IS(err, ErrorCodeConfigFileNotFound) {
+			return Config{}, nil, err
+		}
+		credentialFile, chk := searchCredentialConfigFile()
+		if !chk {
+			return ExplainEnv()
+		}
+		configFile = credentialFile
+	}
+	return ExplainFile(configFile)
+}
+
+// ExplainFile loads the configuration file like LoadConfigFile and returns where each of its values came from
+func ExplainFile(file string) (Config, []ValueSource, error) {
+	config, err := LoadConfigFile(file)
+	if err != nil {
+		return Config{}, nil, err
+	}
+	content, err := files.ReadFile(file)
+	if err != nil {
+		return Config{}, nil, errorex.New(ErrorCodeConfigFileNotLoaded, "Configuration file not loaded", err.Error())
+	}
+	document, err := decodeConfigDocument(file, []byte(content))
+	if err != nil {
+		return Config{}, nil, errorex.New(ErrorCodeConfigFileParseError, "Configuration file parse error", err.Error())
+	}
+	fileSource := "file " + file
+	if isSopsDocument(document) {
+		fileSource = "SOPS encrypted " + fileSource
+	}
+	return config, explainValues(config, func(field configField) string {
+		value, ok := documentValue(document, field.field)
+		switch {
+		case ok:
+			if text, isText := value.(string); isText && IsEncrypted(text) {
+				return "encrypted value in " + fileSource
+			}
+			return fileSource
+		case field.field == "password" && config.PasswordCommand != "":
+			return sourcePasswordCommand
+		case field.field == "password":
+			return "password file " + PgpassFile()
+		}
+		return sourceDefault
+	}), nil
+}
+
+// ExplainEnv loads the configuration like LoadFromEnv and returns where each of its values came from
+func ExplainEnv() (Config, []ValueSource, error) {
+	config, err := LoadFromEnv()
+	if err != nil {
+		return Config{}, nil, err
+	}
+	var secret Config
+	secretSource := envSource("DB_SECRET_JSON", "") + " payload"
+	if payload, chk := lookupEnv("DB_SECRET_JSON"); chk {
+		secret, _ = ParseSecretJSON([]byte(payload))
+	}
+	fromSecret := func(name string, inSecret bool) string {
+		if inSecret {
+			return envSource(name, secretSource)
+		}
+		return envSource(name, sourceDefault)
+	}
+	return config, explainValues(config, func(field configField) string {
+		switch field.field {
+		case "type":
+			return fromSecret("DB_TYPE", secret.Type != 0)
+		case "host":
+			return fromSecret("DB_HOST", secret.Host != "")
+		case "port":
+			return fromSecret("DB_PORT", secret.Port != 0)
+		case "user":
+			return fromSecret("DB_USER", secret.User != "")
+		case "password":
+			if _, chk := lookupEnv("DB_PASSWORD"); chk {
+				return envSource("DB_PASSWORD", "")
+			}
+			// the password command only runs when the secret payload has no password
+			if secret.Password != "" {
+				return secretSource
+			}
+			if config.PasswordCommand != "" {
+				return sourcePasswordCommand
+			}
+			return "password file " + PgpassFile()
+		case "passwordCommand":
+			return envSource("DB_PASSWORD_COMMAND", secretSource)
+		case "database":
+			return fromSecret("DB_DATABASE", secret.Database != "")
+		}
+		// the ssl and sqlite values, the secret payloads only have ssl values
+		return fromSecret(field.env, strings.HasPrefix(field.field, "ssl.") && secret.SSL != nil)
+	}), nil
+}
+
+// envSource describes the source of the environment variable value, the fallback is returned when neither the
+// variable nor its systemd credential is set
+func envSource(name string, fallback string) string {
+	if _, chk := env.ChkString(name); chk {
+		return name + " environment variable"
+	}
+	if _, chk := readCredential(CredentialName(name)); chk {
+		return CredentialName(name) + " systemd credential"
+	}
+	return fallback
+}
+
+// explainValues lists the configuration values with the source returned by the source function, the empty values
+// are listed as not set
+func explainValues(config Config, source func(field configField) string) []ValueSource {
+	var values []ValueSource
+	for _, field := range configFields(config) {
+		if field.omitted {
+			continue
+		}
+		value := ValueSource{Field: field.field, Value: field.value, Source: sourceNotSet}
+		if field.value != "" {
+			value.Source = source(field)
+			if field.secret {
+				value.Value = redactedValue
+			}
+		}
+		values = append(values, value)
+	}
+	return values
+}
+
+// documentValue returns the value of the dotted field path inside the decoded document, the keys are matched
+// case-insensitively like encoding/json does
+func documentValue(document interface{}, field string) (interface{}, bool) {
+	value := document
+	for _, key := range strings.Split(field, ".") {
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		found := false
+		for name, item := range object {
+			if strings.EqualFold(name, key) {
+				value, found = item, true
+				break
+			}
+		}
+		if !found {
+			return nil, false
+		}
+	}
+	return value, true
+}
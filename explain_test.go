@@ -0,0 +1,184 @@
+/*
+ * © 2023 fkmatsuda
+
+ * This file is licensed under the terms of the MIT license. Permission is hereby
+ * granted, free of charge, to any person obtaining a copy of this software and
+ * associated documentation files (the "Software"), to deal in the Software without
+ * restriction, including without limitation the rights to use, copy, modify,
+ * merge, publish, and/or distribute copies of the Software, and to permit persons
+ * to whom the Software is furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS," WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY,
+ * WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+ * CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ *
+ */
+
+package dbconfig
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"testing"
+
+	"github.com/fkmatsuda-dev/commons/files"
+)
+
+func TestExplain(t *testing.T) {
+
+	// Create a temporary directory inside system temp directory
+	dirName, err := files.CreateTempDir()
+	if err != nil {
+		t.Errorf("Error creating temporary directory: %s", err.Error())
+		return
+	}
+	defer func() {
+		_ = files.CleanupTempDirs()
+	}()
+
+	// sources returns the source of each field
+	sources := func(values []ValueSource) map[string]string {
+		result := map[string]string{}
+		for _, value := range values {
+			result[value.Field] = value.Source
+		}
+		return result
+	}
+
+	// Test explain configuration file
+	t.Run("Test explain configuration file", func(t *testing.T) {
+		t.Setenv("DBCONFIG_PERMISSION_CHECK", "off")
+		t.Setenv("CREDENTIALS_DIRECTORY", "")
+		key, err := GenerateKey()
+		if err != nil {
+			t.Errorf("Error generating key: %s", err.Error())
+			return
+		}
+		t.Setenv("DBCONFIG_KEY", key)
+		parsedKey, _ := ParseKey(key)
+		password, err := Encrypt("secret", parsedKey)
+		if err != nil {
+			t.Errorf("Error encrypting password: %s", err.Error())
+			return
+		}
+		configFile := dirName + "/dbconfig.json"
+		content := fmt.Sprintf(`{"type": "POSTGRESQL", "Host": "localhost", "port": 5432, "user": "app",
+			"password": "%s", "database": "app"}`, password)
+		if err := os.WriteFile(configFile, []byte(content), 0600); err != nil {
+			t.Errorf("Error writing config file: %s", err.Error())
+			return
+		}
+
+		config, values, err := Explain(dirName)
+		if err != nil {
+			t.Errorf("Error explaining configuration: %s", err.Error())
+			return
+		}
+		if config.Password != "secret" {
+			t.Errorf("The password must be decrypted")
+			return
+		}
+		expected := map[string]string{
+			"type":            "file " + configFile,
+			"host":            "file " + configFile,
+			"password":        "encrypted value in file " + configFile,
+			"passwordCommand": sourceNotSet,
+		}
+		got := sources(values)
+		for field, source := range expected {
+			if got[field] != source {
+				t.Errorf("The source \"%s\" expected for %s, got \"%s\"", source, field, got[field])
+			}
+		}
+		for _, value := range values {
+			if value.Field == "password" && value.Value != redactedValue {
+				t.Errorf("The password must be redacted")
+			}
+		}
+	})
+
+	// Test explain environment variables
+	t.Run("Test explain environment variables", func(t *testing.T) {
+		credentialsDir := dirName + "/credentials"
+		if err := os.MkdirAll(credentialsDir, 0700); err != nil {
+			t.Errorf("Error creating credentials directory: %s", err.Error())
+			return
+		}
+		if err := os.WriteFile(credentialsDir+"/db-password", []byte("secret\n"), 0600); err != nil {
+			t.Errorf("Error writing credential: %s", err.Error())
+			return
+		}
+		t.Setenv("CREDENTIALS_DIRECTORY", credentialsDir)
+		t.Setenv("DB_SECRET_JSON", `{"host": "db.example.com", "dbname": "orders"}`)
+		t.Setenv("DB_TYPE", "POSTGRESQL")
+		t.Setenv("DB_USER", "app")
+
+		_, values, err := ExplainEnv()
+		if err != nil {
+			t.Errorf("Error explaining configuration: %s", err.Error())
+			return
+		}
+		expected := map[string]string{
+			"type":     "DB_TYPE environment variable",
+			"host":     "DB_SECRET_JSON environment variable payload",
+			"port":     sourceDefault,
+			"user":     "DB_USER environment variable",
+			"password": "db-password systemd credential",
+			"database": "DB_SECRET_JSON environment variable payload",
+		}
+		got := sources(values)
+		for field, source := range expected {
+			if got[field] != source {
+				t.Errorf("The source \"%s\" expected for %s, got \"%s\"", source, field, got[field])
+			}
+		}
+	})
+
+	// Test explain password command
+	t.Run("Test explain password command", func(t *testing.T) {
+		if runtime.GOOS == "windows" {
+			t.Skip("The password command tests use shell commands")
+		}
+		t.Setenv("DBCONFIG_PERMISSION_CHECK", "off")
+		t.Setenv("CREDENTIALS_DIRECTORY", "")
+		t.Setenv("PGPASSFILE", dirName+"/missing-pgpass")
+		configFile := dirName + "/command.json"
+		content := `{"type": "POSTGRESQL", "host": "localhost", "user": "app", "passwordCommand": "echo secret",
+			"database": "app"}`
+		if err := os.WriteFile(configFile, []byte(content), 0600); err != nil {
+			t.Errorf("Error writing config file: %s", err.Error())
+			return
+		}
+		_, fileValues, err := ExplainFile(configFile)
+		if err != nil {
+			t.Errorf("Error explaining configuration: %s", err.Error())
+			return
+		}
+
+		t.Setenv("DB_TYPE", "POSTGRESQL")
+		t.Setenv("DB_HOST", "localhost")
+		t.Setenv("DB_USER", "app")
+		t.Setenv("DB_DATABASE", "app")
+		t.Setenv("DB_PASSWORD_COMMAND", "echo secret")
+		_, envValues, err := ExplainEnv()
+		if err != nil {
+			t.Errorf("Error explaining configuration: %s", err.Error())
+			return
+		}
+
+		// both sources use the same description
+		for _, values := range [][]ValueSource{fileValues, envValues} {
+			if source := sources(values)["password"]; source != sourcePasswordCommand {
+				t.Errorf("The source \"%s\" expected for password, got \"%s\"", sourcePasswordCommand, source)
+			}
+		}
+	})
+
+}
@@ -0,0 +1,249 @@
+/*
+ * © 2023 fkmatsuda
+
+ * This file is licensed under the terms of the MIT license. Permission is hereby
+ * granted, free of charge, to any person obtaining a copy of this software and
+ * associated documentation files (the "Software"), to deal in the Software without
+ * restriction, including without limitation the rights to use, copy, modify,
+ * merge, publish, and/or distribute copies of the Software, and to permit persons
+ * to whom the Software is furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS," WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY,
+ * WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+ * CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ *
+ */
+
+package dbconfig
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/fkmatsuda-dev/commons/errorex"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	configFormatError    = "Configuration format not supported"
+	configFileWriteError = "Configuration file write error"
+)
+
+// redactedValue replaces the secrets of the redacted configurations
+const redactedValue = "********"
+
+// Redacted returns a copy of the configuration with the password replaced, so it can be printed or logged
+func (c Config) Redacted() Config {
+	if c.Password != "" {
+		c.Password = redactedValue
+	}
+	if c.SSL != nil {
+		ssl := *c.SSL
+		c.SSL = &ssl
+	}
+	return c
+}
+
+// EnvVars returns the configuration as the NAME=value DB_* environment variables read by LoadFromEnv, the variables of
+// the empty values are omitted
+func (c Config) EnvVars() []string {
+	var vars []string
+	add := func(name string, value string) {
+		if value != "" {
+			vars = append(vars, name+"="+value)
+		}
+	}
+	add("DB_TYPE", c.Type.String())
+	add("DB_HOST", c.Host)
+	if c.Port != 0 {
+		add("DB_PORT", strconv.Itoa(int(c.Port)))
+	}
+	add("DB_USER", c.User)
+	add("DB_PASSWORD", c.Password)
+	add("DB_PASSWORD_COMMAND", c.PasswordCommand)
+	add("DB_DATABASE", c.Database)
+	if c.SSL != nil {
+		add("DB_SSL_MODE", c.SSL.Mode.String())
+		add("DB_SSL_CA", c.SSL.Ca)
+		add("DB_SSL_CERT", c.SSL.Cert)
+		add("DB_SSL_KEY", c.SSL.Key)
+	}
+	if c.Sqlserver != nil {
+		add("DB_SQLSERVER_INSTANCE", c.Sqlserver.Instance)
+	}
+	if c.Oracle != nil {
+		add("DB_ORACLE_SERVICE_NAME", c.Oracle.ServiceName)
+		add("DB_ORACLE_SID", c.Oracle.SID)
+		add("DB_ORACLE_TNS_ALIAS", c.Oracle.TNSAlias)
+		add("DB_ORACLE_TNS_ADMIN", c.Oracle.TNSAdmin)
+	}
+	if c.Yugabyte != nil {
+		if c.Yugabyte.LoadBalance {
+			add("DB_YUGABYTE_LOAD_BALANCE", "true")
+		}
+		add("DB_YUGABYTE_TOPOLOGY_KEYS", c.Yugabyte.TopologyKeys)
+	}
+	if c.Mongodb != nil {
+		add("DB_MONGODB_HOSTS", strings.Join(c.Mongodb.Hosts, ","))
+		if c.Mongodb.SRV {
+			add("DB_MONGODB_SRV", "true")
+		}
+		add("DB_MONGODB_REPLICA_SET", c.Mongodb.ReplicaSet)
+		add("DB_MONGODB_AUTH_SOURCE", c.Mongodb.AuthSource)
+		add("DB_MONGODB_AUTH_MECHANISM", c.Mongodb.AuthMechanism)
+	}
+	if c.Redis != nil {
+		add("DB_REDIS_MODE", c.Redis.Mode.String())
+		add("DB_REDIS_MASTER_NAME", c.Redis.MasterName)
+		add("DB_REDIS_ADDRS", strings.Join(c.Redis.Addrs, ","))
+	}
+	if c.Clickhouse != nil {
+		add("DB_CLICKHOUSE_PROTOCOL", c.Clickhouse.Protocol.String())
+		add("DB_CLICKHOUSE_COMPRESSION", c.Clickhouse.Compression)
+		add("DB_CLICKHOUSE_HOSTS", strings.Join(c.Clickhouse.Hosts, ","))
+	}
+	if c.Sqlite != nil {
+		add("DB_SQLITE_JOURNAL_MODE", c.Sqlite.JournalMode.String())
+		if c.Sqlite.BusyTimeout != 0 {
+			add("DB_SQLITE_BUSY_TIMEOUT", strconv.FormatUint(uint64(c.Sqlite.BusyTimeout), 10))
+		}
+		if c.Sqlite.ForeignKeys {
+			add("DB_SQLITE_FOREIGN_KEYS", "true")
+		}
+	}
+	return vars
+}
+
+// MarshalYAML marshals the configuration with the json field names, order and enum strings
+func (c Config) MarshalYAML() (interface{}, error) {
+	// json is a subset of yaml, so the json document is decoded as a yaml node in block style
+	content, err := json.Marshal(c)
+	if err != nil {
+		return nil, err
+	}
+	var document yaml.Node
+	if err := yaml.Unmarshal(content, &document); err != nil {
+		return nil, err
+	}
+	blockStyle(&document)
+	return document.Content[0], nil
+}
+
+// blockStyle clears the flow style of the node and of its children
+func blockStyle(node *yaml.Node) {
+	node.Style &^= yaml.FlowStyle
+	if node.Kind == yaml.ScalarNode && node.Tag == "!!str" {
+		node.Style &^= yaml.DoubleQuotedStyle
+	}
+	for _, child := range node.Content {
+		blockStyle(child)
+	}
+}
+
+// Encode encodes the configuration in the json, yaml, toml, env, url or shell format. The env format has the DB_*
+// variables of EnvVars quoted for the shells and the .env file loaders, the shell format has the same variables as
+// export commands and the url format is the database URL of Config.URL
+func (c Config) Encode(format string) ([]byte, error) {
+	switch format {
+	case "json":
+		content, err := json.MarshalIndent(c, "", "  ")
+		if err != nil {
+			return nil, errorex.New(ErrorCodeConfigFormatError, configFormatError, err.Error())
+		}
+		return append(content, '\n'), nil
+	case "yaml":
+		content, err := yaml.Marshal(c)
+		if err != nil {
+			return nil, errorex.New(ErrorCodeConfigFormatError, configFormatError, err.Error())
+		}
+		return content, nil
+	case "toml":
+		var content bytes.Buffer
+		if err := toml.NewEncoder(&content).Encode(c); err != nil {
+			return nil, errorex.New(ErrorCodeConfigFormatError, configFormatError, err.Error())
+		}
+		return content.Bytes(), nil
+	case "env":
+		var content bytes.Buffer
+		for _, variable := range c.EnvVars() {
+			name, value, _ := strings.Cut(variable, "=")
+			content.WriteString(name + "=" + shellQuote(value) + "\n")
+		}
+		return content.Bytes(), nil
+	case "shell":
+		var content bytes.Buffer
+		for _, variable := range c.EnvVars() {
+			name, value, _ := strings.Cut(variable, "=")
+			content.WriteString("export " + name + "=" + shellQuote(value) + "\n")
+		}
+		return content.Bytes(), nil
+	case "url":
+		databaseURL, err := c.URL()
+		if err != nil {
+			return nil, err
+		}
+		return []byte(databaseURL + "\n"), nil
+	}
+	return nil, errorex.New(
+		ErrorCodeConfigFormatError,
+		configFormatError,
+		fmt.Sprintf("\"%s\" format is not supported", format),
+	)
+}
+
+// ConfigFileFormat returns the format of the configuration file by its extension: json, yaml (.yaml and .yml), toml
+// or env (.env)
+func ConfigFileFormat(file string) (string, error) {
+	switch strings.ToLower(filepath.Ext(file)) {
+	case ".json":
+		return "json", nil
+	case ".yaml", ".yml":
+		return "yaml", nil
+	case ".toml":
+		return "toml", nil
+	case ".env":
+		return "env", nil
+	}
+	return "", errorex.New(
+		ErrorCodeConfigFormatError,
+		configFormatError,
+		fmt.Sprintf("the format of the \"%s\" file is unknown", file),
+	)
+}
+
+// WriteConfigFile writes the configuration to the file with the 0600 mode in the format of the file extension
+func WriteConfigFile(file string, config Config) error {
+	format, err := ConfigFileFormat(file)
+	if err != nil {
+		return err
+	}
+	content, err := config.Encode(format)
+	if err != nil {
+		return err
+	}
+	// the file is replaced by a 0600 temporary file, the content is never readable with the mode of an existing file
+	if err := writeSecretFile(file, content); err != nil {
+		return errorex.New(ErrorCodeConfigFileWriteError, configFileWriteError, err.Error())
+	}
+	return nil
+}
+
+// shellQuote quotes the value with single quotes when it has any character special to the shells
+func shellQuote(value string) string {
+	const safe = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789-_./:@,+%"
+	if value != "" && strings.Trim(value, safe) == "" {
+		return value
+	}
+	return "'" + strings.ReplaceAll(value, "'", `'"'"'`) + "'"
+}
@@ -0,0 +1,243 @@
+/*
+ * © 2023 fkmatsuda
+
+ * This file is licensed under the terms of the MIT license. Permission is hereby
+ * granted, free of charge, to any person obtaining a copy of this software and
+ * associated documentation files (the "Software"), to deal in the Software without
+ * restriction, including without limitation the rights to use, copy, modify,
+ * merge, publish, and/or distribute copies of the Software, and to permit persons
+ * to whom the Software is furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS," WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY,
+ * WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+ * CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ *
+ */
+
+package dbconfig
+
+import (
+	"encoding/json"
+	"os"
+	"reflect"
+	"runtime"
+	"testing"
+
+	"github.com/fkmatsuda-dev/commons/errorex"
+	"github.com/fkmatsuda-dev/commons/files"
+	"gopkg.in/yaml.v3"
+)
+
+func TestExport(t *testing.T) {
+
+	config := Config{
+		Type:     DbTypePostgres,
+		Host:     "localhost",
+		Port:     5432,
+		User:     "postgres",
+		Password: "true",
+		Database: "postgres",
+		SSL: &SSLConfig{
+			Mode: SSLModeVerifyCA,
+			Ca:   "ca.crt",
+		},
+	}
+
+	// Test redacted configuration
+	t.Run("Test redacted configuration", func(t *testing.T) {
+		redacted := config.Redacted()
+		if redacted.Password != redactedValue {
+			t.Errorf("The password must be redacted")
+			return
+		}
+		if config.Password != "true" {
+			t.Errorf("The original configuration must not be changed")
+			return
+		}
+		if Config.Redacted(Config{}).Password != "" {
+			t.Errorf("An empty password must stay empty")
+			return
+		}
+	})
+
+	// Test environment variables
+	t.Run("Test environment variables", func(t *testing.T) {
+		expected := []string{
+			"DB_TYPE=POSTGRESQL",
+			"DB_HOST=localhost",
+			"DB_PORT=5432",
+			"DB_USER=postgres",
+			"DB_PASSWORD=true",
+			"DB_DATABASE=postgres",
+			"DB_SSL_MODE=verify-ca",
+			"DB_SSL_CA=ca.crt",
+		}
+		if vars := config.EnvVars(); !reflect.DeepEqual(vars, expected) {
+			t.Errorf("The variables %v expected, got %v", expected, vars)
+			return
+		}
+	})
+
+	// Test encode env
+	t.Run("Test encode env", func(t *testing.T) {
+		withCommand := config
+		withCommand.Password = ""
+		withCommand.PasswordCommand = "op read 'op://vault/db/password'"
+		content, err := withCommand.Encode("env")
+		if err != nil {
+			t.Errorf("Error encoding config: %s", err.Error())
+			return
+		}
+		expected := `DB_TYPE=POSTGRESQL
+DB_HOST=localhost
+DB_PORT=5432
+DB_USER=postgres
+DB_PASSWORD_COMMAND='op read '"'"'op://vault/db/password'"'"''
+DB_DATABASE=postgres
+DB_SSL_MODE=verify-ca
+DB_SSL_CA=ca.crt
+`
+		if string(content) != expected {
+			t.Errorf("The env\n%s\nexpected, got\n%s", expected, content)
+			return
+		}
+		if _, err := config.Encode("xml"); !errorex.IS(err, ErrorCodeConfigFormatError) {
+			t.Errorf("Configuration format error expected")
+			return
+		}
+	})
+
+	// Test write and load configuration files
+	t.Run("Test write and load configuration files", func(t *testing.T) {
+		dirName, err := files.CreateTempDir()
+		if err != nil {
+			t.Errorf("Error creating temporary directory: %s", err.Error())
+			return
+		}
+		defer func() {
+			_ = files.CleanupTempDirs()
+		}()
+		for _, format := range []string{"json", "yaml", "toml"} {
+			configFile := dirName + "/dbconfig." + format
+			if err := WriteConfigFile(configFile, config); err != nil {
+				t.Errorf("Error writing config file: %s", err.Error())
+				return
+			}
+			if info, err := os.Stat(configFile); err != nil || info.Mode().Perm() != 0600 {
+				t.Errorf("The %s config file mode must be 0600", format)
+				return
+			}
+			loaded, err := LoadConfig(dirName)
+			if err != nil {
+				t.Errorf("Error loading %s config file: %s", format, err.Error())
+				return
+			}
+			if !config.compare(loaded) {
+				t.Errorf("The loaded %s config is different from the original config", format)
+				return
+			}
+			_ = os.Remove(configFile)
+		}
+		// an existing readable file is replaced by a 0600 file
+		existingFile := dirName + "/existing.json"
+		if err := os.WriteFile(existingFile, []byte("{}"), 0644); err != nil {
+			t.Errorf("Error writing config file: %s", err.Error())
+			return
+		}
+		if err := WriteConfigFile(existingFile, config); err != nil {
+			t.Errorf("Error writing config file: %s", err.Error())
+			return
+		}
+		if info, err := os.Stat(existingFile); err != nil || runtime.GOOS != "windows" && info.Mode().Perm() != 0600 {
+			t.Errorf("The existing config file mode must be 0600")
+			return
+		}
+		if err := WriteConfigFile(dirName+"/dbconfig.xml", config); !errorex.IS(err, ErrorCodeConfigFormatError) {
+			t.Errorf("Configuration format error expected")
+			return
+		}
+	})
+
+	// Test marshal and unmarshal json
+	t.Run("Test marshal and unmarshal json", func(t *testing.T) {
+		// the json keys are the lower camel case keys of the configuration files, not the exported field names
+		content, err := json.Marshal(config)
+		if err != nil {
+			t.Errorf("Error marshaling config: %s", err.Error())
+			return
+		}
+		expected := `{"type":"POSTGRESQL","host":"localhost","port":5432,"user":"postgres","password":"true",` +
+			`"database":"postgres","ssl":{"mode":"verify-ca","ca":"ca.crt"}}`
+		if string(content) != expected {
+			t.Errorf("The json\n%s\nexpected, got\n%s", expected, content)
+			return
+		}
+
+		// the exported field names written by the previous versions are still accepted
+		var loaded Config
+		previous := `{"Type":"POSTGRESQL","Host":"localhost","Port":5432,"User":"postgres","Password":"true",` +
+			`"Database":"postgres","SSL":{"Mode":"verify-ca","Ca":"ca.crt"}}`
+		if err := json.Unmarshal([]byte(previous), &loaded); err != nil {
+			t.Errorf("Error unmarshaling config: %s", err.Error())
+			return
+		}
+		if !config.compare(loaded) {
+			t.Errorf("The configuration %+v expected, got %+v", config, loaded)
+			return
+		}
+	})
+
+	// Test marshal and unmarshal yaml
+	t.Run("Test marshal and unmarshal yaml", func(t *testing.T) {
+		content, err := yaml.Marshal(config)
+		if err != nil {
+			t.Errorf("Error marshaling config: %s", err.Error())
+			return
+		}
+		expected := `type: POSTGRESQL
+host: localhost
+port: 5432
+user: postgres
+password: "true"
+database: postgres
+ssl:
+    mode: verify-ca
+    ca: ca.crt
+`
+		if string(content) != expected {
+			t.Errorf("The yaml\n%s\nexpected, got\n%s", expected, content)
+			return
+		}
+
+		// the yaml must load back to the same configuration
+		dirName, err := files.CreateTempDir()
+		if err != nil {
+			t.Errorf("Error creating temporary directory: %s", err.Error())
+			return
+		}
+		defer func() {
+			_ = files.CleanupTempDirs()
+		}()
+		if err := os.WriteFile(dirName+"/dbconfig.yaml", content, 0600); err != nil {
+			t.Errorf("Error writing config file: %s", err.Error())
+			return
+		}
+		t.Setenv("DBCONFIG_PERMISSION_CHECK", "off")
+		loaded, err := LoadConfigFile(dirName + "/dbconfig.yaml")
+		if err != nil {
+			t.Errorf("Error loading config: %s", err.Error())
+			return
+		}
+		if !config.compare(loaded) {
+			t.Errorf("The loaded config is different from the original config")
+			return
+		}
+	})
+
+}
@@ -0,0 +1,85 @@
+/*
+ * © 2023 fkmatsuda
+
+ * This file is licensed under the terms of the MIT license. Permission is hereby
+ * granted, free of charge, to any person obtaining a copy of this software and
+ * associated documentation files (the "Software"), to deal in the Software without
+ * restriction, including without limitation the rights to use, copy, modify,
+ * merge, publish, and/or distribute copies of the Software, and to permit persons
+ * to whom the Software is furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS," WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY,
+ * WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+ * CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ *
+ */
+
+package dbconfig
+
+// DbTypeFamily maps the database types that speak the protocol of another type to the type of the protocol family,
+// the types that are not in the map are their own family
+var DbTypeFamily = map[DbType]DbType{
+	DbTypeCockroachdb:        DbTypePostgres,
+	DbTypeYugabytedb:         DbTypePostgres,
+	DbTypePostgresCompatible: DbTypePostgres,
+	DbTypeMariadb:            DbTypeMysql,
+	DbTypeTidb:               DbTypeMysql,
+}
+
+// Family returns the type of the protocol family of the DbType, like DbTypeMysql for MariaDB and TiDB or DbTypePostgres
+// for CockroachDB, YugabyteDB and the other PostgreSQL compatible engines, so the code that handles a protocol can
+// switch on the family instead of listing each engine
+func (s DbType) Family() DbType {
+	if family, ok := DbTypeFamily[s]; ok {
+		return family
+	}
+	return s
+}
+
+// DbCapability is a set of SQL features of a database type
+type DbCapability uint16
+
+const (
+	// CapabilityReturning is the RETURNING clause of the INSERT, UPDATE and DELETE statements
+	CapabilityReturning DbCapability = 1 << iota
+	// CapabilitySequences are the CREATE SEQUENCE sequences
+	CapabilitySequences
+	// CapabilityTransactionalDDL is the rollback of the schema changes made inside a transaction
+	CapabilityTransactionalDDL
+	// CapabilityLastInsertID is the support of sql.Result.LastInsertId by the drivers
+	CapabilityLastInsertID
+)
+
+// DbTypeCapabilities maps a DbType to the SQL features of its current releases, the unknown PostgreSQL compatible
+// engines have no capabilities
+var DbTypeCapabilities = map[DbType]DbCapability{
+	DbTypeMysql:              CapabilityLastInsertID,
+	DbTypeMariadb:            CapabilityReturning | CapabilitySequences | CapabilityLastInsertID,
+	DbTypeTidb:               CapabilitySequences | CapabilityLastInsertID,
+	DbTypePostgres:           CapabilityReturning | CapabilitySequences | CapabilityTransactionalDDL,
+	DbTypeCockroachdb:        CapabilityReturning | CapabilitySequences,
+	DbTypeYugabytedb:         CapabilityReturning | CapabilitySequences,
+	DbTypePostgresCompatible: 0,
+	DbTypeSqlite:             CapabilityReturning | CapabilityTransactionalDDL | CapabilityLastInsertID,
+	DbTypeSqlserver:          CapabilitySequences | CapabilityTransactionalDDL,
+	DbTypeOracle:             CapabilitySequences,
+	DbTypeMongodb:            0,
+	DbTypeRedis:              0,
+	DbTypeClickhouse:         0,
+}
+
+// Capabilities returns the SQL features of the DbType
+func (s DbType) Capabilities() DbCapability {
+	return DbTypeCapabilities[s]
+}
+
+// Has returns true when the DbType has all the given capabilities
+func (s DbType) Has(capabilities DbCapability) bool {
+	return s.Capabilities()&capabilities == capabilities
+}
@@ -0,0 +1,101 @@
+/*
+ * © 2023 fkmatsuda
+
+ * This file is licensed under the terms of the MIT license. Permission is hereby
+ * granted, free of charge, to any person obtaining a copy of this software and
+ * associated documentation files (the "Software"), to deal in the Software without
+ * restriction, including without limitation the rights to use, copy, modify,
+ * merge, publish, and/or distribute copies of the Software, and to permit persons
+ * to whom the Software is furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS," WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY,
+ * WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+ * CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ *
+ */
+
+package dbconfig
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+)
+
+// FingerprintOptions are the options of Config.FingerprintWithOptions
+type FingerprintOptions struct {
+	// ExcludeCredentials leaves the user, the password and the SSL client certificate and key out of the fingerprint,
+	// so the replicas of a service with their own credentials have the same fingerprint
+	ExcludeCredentials bool
+	// PasswordKey is the secret key of the HMAC-SHA256 of the password. When it is empty the key is derived from the
+	// type, host, port, user and database, which keeps the equal passwords of different servers apart but does not
+	// stop a dictionary search by someone who knows those settings, so set it when the fingerprints are published
+	PasswordKey []byte
+}
+
+// Fingerprint returns a hex encoded SHA-256 hash of the values used to open the connections, the SSL files are hashed
+// by their content instead of their names and the password by its HMAC (see FingerprintOptions.PasswordKey). Equal
+// fingerprints mean that the open connections can be kept
+func (c Config) Fingerprint() string {
+	return c.FingerprintWithOptions(FingerprintOptions{})
+}
+
+// FingerprintWithOptions returns the fingerprint of the configuration like Fingerprint with the given options
+func (c Config) FingerprintWithOptions(options FingerprintOptions) string {
+	hash := sha256.New()
+	for _, field := range configFields(c) {
+		// the empty values are left out, so new settings do not change the fingerprints of the configurations
+		// without them
+		if !field.reconnect || field.value == "" || (options.ExcludeCredentials && field.credential) {
+			continue
+		}
+		value := field.value
+		if field.file {
+			value = fileFingerprint(value)
+		}
+		if field.secret {
+			value = "hmac-sha256 " + c.passwordMAC(value, options.PasswordKey)
+		}
+		// the length prefix keeps the encoding unambiguous
+		fmt.Fprintf(hash, "%s=%d:%s\n", field.field, len(value), value)
+	}
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// passwordMAC returns the hex encoded HMAC-SHA256 of the password with the key, or with the key derived from the
+// connection settings when the key is empty
+func (c Config) passwordMAC(password string, key []byte) string {
+	if len(key) == 0 {
+		derived := sha256.Sum256([]byte(fmt.Sprintf("dbconfig fingerprint\n%s\n%s\n%d\n%s\n%s",
+			c.Type, c.Host, c.Port, c.User, c.Database)))
+		key = derived[:]
+	}
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(password))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// fileFingerprint returns the SHA-256 hash of the file content, or the file name when the file cannot be read
+func fileFingerprint(name string) string {
+	file, err := os.Open(name)
+	if err != nil {
+		return "unreadable " + name
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "unreadable " + name
+	}
+	return "sha256 " + hex.EncodeToString(hash.Sum(nil))
+}
@@ -0,0 +1,129 @@
+/*
+ * © 2023 fkmatsuda
+
+ * This file is licensed under the terms of the MIT license. Permission is hereby
+ * granted, free of charge, to any person obtaining a copy of this software and
+ * associated documentation files (the "Software"), to deal in the Software without
+ * restriction, including without limitation the rights to use, copy, modify,
+ * merge, publish, and/or distribute copies of the Software, and to permit persons
+ * to whom the Software is furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS," WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY,
+ * WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+ * CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ *
+ */
+
+package dbconfig
+
+import (
+	"testing"
+
+	"github.com/fkmatsuda-dev/commons/files"
+)
+
+func TestFingerprint(t *testing.T) {
+
+	// Create a temporary directory inside system temp directory
+	dirName, err := files.CreateTempDir()
+	if err != nil {
+		t.Errorf("Error creating temporary directory: %s", err.Error())
+		return
+	}
+	defer func() {
+		_ = files.CleanupTempDirs()
+	}()
+
+	for name, content := range map[string]string{"ca.crt": "ca", "copy.crt": "ca", "other.crt": "other"} {
+		if err := files.WriteFile(dirName+"/"+name, content); err != nil {
+			t.Errorf("Error writing certificate: %s", err.Error())
+			return
+		}
+	}
+
+	config := Config{
+		Type:            DbTypePostgres,
+		Host:            "db.example.com",
+		Port:            5432,
+		User:            "app",
+		Password:        "secret",
+		PasswordCommand: "pass show db/app",
+		Database:        "orders",
+		SSL:             &SSLConfig{Mode: SSLModeVerifyCA, Ca: dirName + "/ca.crt"},
+	}
+	fingerprint := config.Fingerprint()
+
+	// Test stable fingerprint
+	t.Run("Test stable fingerprint", func(t *testing.T) {
+		if len(fingerprint) != 64 {
+			t.Errorf("A hex encoded SHA-256 hash expected, got %s", fingerprint)
+			return
+		}
+		same := config
+		same.SSL = &SSLConfig{Mode: SSLModeVerifyCA, Ca: dirName + "/copy.crt"}
+		same.PasswordCommand = ""
+		if same.Fingerprint() != fingerprint {
+			t.Errorf("The fingerprint must depend on the SSL file contents and not on their names")
+			return
+		}
+	})
+
+	// Test changed fingerprint
+	t.Run("Test changed fingerprint", func(t *testing.T) {
+		changes := map[string]func(c *Config){
+			"host":     func(c *Config) { c.Host = "replica.example.com" },
+			"password": func(c *Config) { c.Password = "other" },
+			"port":     func(c *Config) { c.Port = 6432 },
+			"database": func(c *Config) { c.Database = "reports" },
+			"ssl ca": func(c *Config) {
+				c.SSL = &SSLConfig{Mode: SSLModeVerifyCA, Ca: dirName + "/other.crt"}
+			},
+			"ssl mode": func(c *Config) { c.SSL = &SSLConfig{Mode: SSLModeRequire} },
+		}
+		for name, change := range changes {
+			changed := config
+			change(&changed)
+			if changed.Fingerprint() == fingerprint {
+				t.Errorf("The fingerprint must change with the %s", name)
+			}
+		}
+	})
+
+	// Test fingerprint with password key
+	t.Run("Test fingerprint with password key", func(t *testing.T) {
+		options := FingerprintOptions{PasswordKey: []byte("fingerprint-key")}
+		if config.FingerprintWithOptions(options) == fingerprint {
+			t.Errorf("The fingerprint must depend on the password key")
+			return
+		}
+		rotated := config
+		rotated.Password = "other"
+		if rotated.FingerprintWithOptions(options) == config.FingerprintWithOptions(options) {
+			t.Errorf("The fingerprint with a password key must change with the password")
+			return
+		}
+	})
+
+	// Test fingerprint without credentials
+	t.Run("Test fingerprint without credentials", func(t *testing.T) {
+		options := FingerprintOptions{ExcludeCredentials: true, PasswordKey: []byte("fingerprint-key")}
+		replica := config
+		replica.User = "replica"
+		replica.Password = "replica-secret"
+		if replica.FingerprintWithOptions(options) != config.FingerprintWithOptions(options) {
+			t.Errorf("The credentials must be excluded from the fingerprint")
+			return
+		}
+		if config.FingerprintWithOptions(options) == fingerprint {
+			t.Errorf("The fingerprint without credentials must be different from the full fingerprint")
+			return
+		}
+	})
+
+}
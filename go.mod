@@ -3,6 +3,15 @@ module github.com/fkmatsuda-dev/dbconfig
 go 1.20
 
 require (
+	filippo.io/age v1.2.1
+	github.com/BurntSushi/toml v1.4.0
 	github.com/fkmatsuda-dev/commons v1.0.0
 	github.com/fkmatsuda-dev/env v1.1.0
+	golang.org/x/term v0.21.0
+	gopkg.in/yaml.v3 v3.0.1
+)
+
+require (
+	golang.org/x/crypto v0.24.0 // indirect
+	golang.org/x/sys v0.21.0 // indirect
 )
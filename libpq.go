@@ -0,0 +1,296 @@
+/*
+ * © 2023 fkmatsuda
+
+ * This file is licensed under the terms of the MIT license. Permission is hereby
+ * granted, free of charge, to any person obtaining a copy of this software and
+ * associated documentation files (the "Software"), to deal in the Software without
+ * restriction, including without limitation the rights to use, copy, modify,
+ * merge, publish, and/or distribute copies of the Software, and to permit persons
+ * to whom the Software is furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS," WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY,
+ * WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+ * CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ *
+ */
+
+package dbconfig
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"github.com/fkmatsuda-dev/commons/errorex"
+	"github.com/fkmatsuda-dev/commons/files"
+)
+
+const (
+	pgServiceNotFound   = "PostgreSQL service not found"
+	pgServiceParseError = "PostgreSQL service file parse error"
+)
+
+// libpqEnv maps the libpq connection parameters to their environment variables
+var libpqEnv = []struct {
+	param string
+	env   string
+}{
+	{"host", "PGHOST"},
+	{"hostaddr", "PGHOSTADDR"},
+	{"port", "PGPORT"},
+	{"dbname", "PGDATABASE"},
+	{"user", "PGUSER"},
+	{"password", "PGPASSWORD"},
+	{"sslmode", "PGSSLMODE"},
+	{"sslrootcert", "PGSSLROOTCERT"},
+	{"sslcert", "PGSSLCERT"},
+	{"sslkey", "PGSSLKEY"},
+}
+
+// libpqSocketDirs are the default Unix-domain socket directories of the libpq builds
+var libpqSocketDirs = []string{"/var/run/postgresql", "/private/tmp", "/tmp"}
+
+// LoadFromLibpqEnv loads the PostgreSQL settings from the libpq environment variables (PGHOST, PGPORT, PGUSER,
+// PGPASSWORD, PGDATABASE, PGSSLMODE, PGSSLROOTCERT, PGSSLCERT and PGSSLKEY) and returns a struct Config.
+// When PGSERVICE is set the service is read from the PGSERVICEFILE or ~/.pg_service.conf file and then from the
+// pg_service.conf file of PGSYSCONFDIR, like in libpq the service settings take precedence over the environment
+// variables. Like in libpq the host defaults to the Unix-domain socket directory (localhost on Windows), the user to
+// the operating system user, the database to the user and the SSL mode to prefer. The password is looked up in the
+// libpq password file when it is not set and it is optional, so the peer, trust and certificate authentications are
+// accepted
+func LoadFromLibpqEnv() (Config, error) {
+	params := make(map[string]string)
+	if service, chk := lookupEnv("PGSERVICE"); chk {
+		serviceParams, err := loadPgService(service)
+		if err != nil {
+			return Config{}, err
+		}
+		params = serviceParams
+	}
+	for _, variable := range libpqEnv {
+		if _, ok := params[variable.param]; ok {
+			continue
+		}
+		if value, chk := lookupEnv(variable.env); chk {
+			params[variable.param] = value
+		}
+	}
+	return libpqConfig(params)
+}
+
+// libpqConfig converts the libpq connection parameters to a validated struct Config
+func libpqConfig(params map[string]string) (Config, error) {
+	config := Config{
+		Type:     DbTypePostgres,
+		Host:     params["host"],
+		Port:     DbTypePostgres.DefaultPort(),
+		User:     params["user"],
+		Password: params["password"],
+		Database: params["dbname"],
+	}
+	if config.Host == "" {
+		config.Host = params["hostaddr"]
+	}
+	if strPort, ok := params["port"]; ok && strPort != "" {
+		port, err := strconv.ParseUint(strPort, 10, 16)
+		if err != nil {
+			return Config{}, errorex.New(
+				ErrorCodeEnvConfigParseError,
+				configurationParseError,
+				fmt.Sprintf("\"%s\" value for port is invalid", strPort),
+			)
+		}
+		config.Port = uint16(port)
+	}
+	if config.Host == "" {
+		config.Host = libpqDefaultHost(config.Port)
+	}
+	if config.User == "" {
+		if current, err := user.Current(); err == nil {
+			config.User = current.Username
+		}
+	}
+	if config.Database == "" {
+		config.Database = config.User
+	}
+
+	// like in libpq the SSL mode defaults to prefer
+	sslMode := SSLModePrefer
+	if strSSLMode := params["sslmode"]; strSSLMode != "" {
+		var err error
+		sslMode, err = ParseSSLMode(strSSLMode)
+		if err != nil {
+			return Config{}, errorex.New(ErrorCodeEnvConfigParseError, configurationParseError, err.Error())
+		}
+	}
+	config.SSL = &SSLConfig{
+		Mode: sslMode,
+		Ca:   libpqFile(params["sslrootcert"], "root.crt"),
+		Cert: libpqFile(params["sslcert"], "postgresql.crt"),
+		Key:  libpqFile(params["sslkey"], "postgresql.key"),
+	}
+
+	// look up the password in the passfile of the service or in the libpq password file
+	if config.Password == "" {
+		if passfile, ok := params["passfile"]; ok && passfile != "" {
+			config.Password, _ = lookupPgpassFile(passfile, config.Host, config.Port, config.Database, config.User)
+		} else {
+			config = resolvePgpassPassword(config)
+		}
+	}
+
+	if err := config.validate("password"); err != nil {
+		return Config{}, err
+	}
+	return config, nil
+}
+
+// libpqDefaultHost returns the socket directory with the server socket of the port, or the first existing socket
+// directory, that libpq uses when the host is not set. Windows has no Unix-domain sockets and uses localhost
+func libpqDefaultHost(port uint16) string {
+	if runtime.GOOS == "windows" {
+		return "localhost"
+	}
+	for _, dir := range libpqSocketDirs {
+		if files.Exists(filepath.Join(dir, fmt.Sprintf(".s.PGSQL.%d", port))) {
+			return dir
+		}
+	}
+	for _, dir := range libpqSocketDirs {
+		if files.Exists(dir) {
+			return dir
+		}
+	}
+	return "localhost"
+}
+
+// libpqFile returns the file or, when it is not set, the libpq default file inside ~/.postgresql if it exists
+func libpqFile(file string, defaultName string) string {
+	if file != "" {
+		return file
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	if defaultFile := filepath.Join(home, ".postgresql", defaultName); files.Exists(defaultFile) {
+		return defaultFile
+	}
+	return ""
+}
+
+// loadPgService reads the parameters of the service from the user service file and then from the system one
+func loadPgService(service string) (map[string]string, error) {
+	var serviceFiles []string
+	if serviceFile, chk := lookupEnv("PGSERVICEFILE"); chk {
+		serviceFiles = append(serviceFiles, serviceFile)
+	} else if home, err := os.UserHomeDir(); err == nil {
+		serviceFiles = append(serviceFiles, filepath.Join(home, ".pg_service.conf"))
+	}
+	if sysConfDir, chk := lookupEnv("PGSYSCONFDIR"); chk {
+		serviceFiles = append(serviceFiles, filepath.Join(sysConfDir, "pg_service.conf"))
+	}
+
+	for _, serviceFile := range serviceFiles {
+		if !files.Exists(serviceFile) {
+			continue
+		}
+		params, found, err := parsePgServiceFile(serviceFile, service)
+		if err != nil {
+			return nil, err
+		}
+		if found {
+			return params, nil
+		}
+	}
+	return nil, errorex.New(
+		ErrorCodePgServiceNotFound,
+		pgServiceNotFound,
+		fmt.Sprintf("definition of service \"%s\" not found in %s", service, strings.Join(serviceFiles, ", ")),
+	)
+}
+
+// parsePgServiceFile reads the parameters of a service from a pg_service.conf file
+func parsePgServiceFile(serviceFile string, service string) (map[string]string, bool, error) {
+	file, err := os.Open(serviceFile)
+	if err != nil {
+		return nil, false, errorex.New(ErrorCodePgServiceParseError, pgServiceParseError, err.Error())
+	}
+	defer func(file *os.File) {
+		_ = file.Close()
+	}(file)
+
+	var params map[string]string
+	inService := false
+	scanner := bufio.NewScanner(file)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			if inService {
+				break
+			}
+			inService = line[1:len(line)-1] == service
+			if inService {
+				params = make(map[string]string)
+			}
+			continue
+		}
+		if !inService {
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			return nil, false, errorex.New(
+				ErrorCodePgServiceParseError,
+				pgServiceParseError,
+				fmt.Sprintf("syntax error in service file \"%s\", line %d", serviceFile, lineNumber),
+			)
+		}
+		params[strings.TrimSpace(key)] = strings.TrimSpace(value)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, false, errorex.New(ErrorCodePgServiceParseError, pgServiceParseError, err.Error())
+	}
+	return params, inService, nil
+}
+
+// LibpqEnv returns the configuration as the NAME=value libpq environment variables read by psql, pg_dump and the other
+// libpq clients, the variables of the empty values are omitted
+func (c Config) LibpqEnv() []string {
+	params := map[string]string{
+		"host":     c.Host,
+		"dbname":   c.Database,
+		"user":     c.User,
+		"password": c.Password,
+	}
+	if c.Port != 0 {
+		params["port"] = strconv.Itoa(int(c.Port))
+	}
+	if c.SSL != nil {
+		params["sslmode"] = c.SSL.Mode.String()
+		params["sslrootcert"] = c.SSL.Ca
+		params["sslcert"] = c.SSL.Cert
+		params["sslkey"] = c.SSL.Key
+	}
+	var vars []string
+	for _, variable := range libpqEnv {
+		if value := params[variable.param]; value != "" {
+			vars = append(vars, variable.env+"="+value)
+		}
+	}
+	return vars
+}
@@ -0,0 +1,333 @@
+/*
+ * © 2023 fkmatsuda
+
+ * This file is licensed under the terms of the MIT license. Permission is hereby
+ * granted, free of charge, to any person obtaining a copy of this software and
+ * associated documentation files (the "Software"), to deal in the Software without
+ * restriction, including without limitation the rights to use, copy, modify,
+ * merge, publish, and/or distribute copies of the Software, and to permit persons
+ * to whom the Software is furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS," WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY,
+ * WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+ * CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ *
+ */
+
+package dbconfig
+
+import (
+	"net/url"
+	"os"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/fkmatsuda-dev/commons/errorex"
+	"github.com/fkmatsuda-dev/commons/files"
+)
+
+func TestLoadFromLibpqEnv(t *testing.T) {
+
+	// Create a temporary home directory inside system temp directory
+	home, err := files.CreateTempDir()
+	if err != nil {
+		t.Errorf("Error creating temporary directory: %s", err.Error())
+		return
+	}
+	defer func() {
+		_ = files.CleanupTempDirs()
+	}()
+
+	err = files.WriteFile(home+"/.pg_service.conf", `# user services
+[orders]
+host=orders.example.com
+port = 5433
+dbname=orders
+user=app
+sslmode=verify-ca
+sslrootcert=/etc/ssl/orders-ca.crt
+
+[reports]
+host=reports.example.com
+`)
+	if err != nil {
+		t.Errorf("Error writing service file: %s", err.Error())
+		return
+	}
+
+	// Test load by libpq environment variables
+	t.Run("Test load by libpq environment variables", func(t *testing.T) {
+		t.Setenv("HOME", home)
+		t.Setenv("PGHOST", "localhost")
+		t.Setenv("PGPORT", "5433")
+		t.Setenv("PGUSER", "postgres")
+		t.Setenv("PGPASSWORD", "postgres")
+		t.Setenv("PGDATABASE", "orders")
+		t.Setenv("PGSSLMODE", "verify-full")
+		t.Setenv("PGSSLROOTCERT", "ca.crt")
+		t.Setenv("PGSSLCERT", "client.crt")
+		t.Setenv("PGSSLKEY", "client.key")
+
+		expected := Config{
+			Type:     DbTypePostgres,
+			Host:     "localhost",
+			Port:     5433,
+			User:     "postgres",
+			Password: "postgres",
+			Database: "orders",
+			SSL: &SSLConfig{
+				Mode: SSLModeVerifyFull,
+				Ca:   "ca.crt",
+				Cert: "client.crt",
+				Key:  "client.key",
+			},
+		}
+
+		config, err := LoadFromLibpqEnv()
+		if err != nil {
+			t.Errorf("Error loading configuration: %s", err.Error())
+			return
+		}
+		if !expected.compare(config) {
+			t.Errorf("The loaded configuration is different from the expected configuration")
+			return
+		}
+	})
+
+	// Test database defaults to user
+	t.Run("Test database defaults to user", func(t *testing.T) {
+		t.Setenv("HOME", home)
+		t.Setenv("PGHOST", "localhost")
+		t.Setenv("PGUSER", "app")
+		t.Setenv("PGPASSWORD", "s3cr3t")
+
+		config, err := LoadFromLibpqEnv()
+		if err != nil {
+			t.Errorf("Error loading configuration: %s", err.Error())
+			return
+		}
+		if config.Database != "app" || config.Port != 5432 || config.SSL == nil || config.SSL.Mode != SSLModePrefer {
+			t.Errorf("The libpq defaults expected, got %+v", config)
+			return
+		}
+	})
+
+	// Test load by service
+	t.Run("Test load by service", func(t *testing.T) {
+		t.Setenv("HOME", home)
+		t.Setenv("PGSERVICE", "orders")
+		t.Setenv("PGHOST", "ignored.example.com")
+		t.Setenv("PGPASSWORD", "s3cr3t")
+
+		expected := Config{
+			Type:     DbTypePostgres,
+			Host:     "orders.example.com",
+			Port:     5433,
+			User:     "app",
+			Password: "s3cr3t",
+			Database: "orders",
+			SSL: &SSLConfig{
+				Mode: SSLModeVerifyCA,
+				Ca:   "/etc/ssl/orders-ca.crt",
+			},
+		}
+
+		// the service settings take precedence over the environment variables
+		config, err := LoadFromLibpqEnv()
+		if err != nil {
+			t.Errorf("Error loading configuration: %s", err.Error())
+			return
+		}
+		if !expected.compare(config) {
+			t.Errorf("The loaded configuration is different from the expected configuration")
+			return
+		}
+	})
+
+	// Test load by service from system service file
+	t.Run("Test load by service from system service file", func(t *testing.T) {
+		sysConfDir, err := files.CreateTempDir()
+		if err != nil {
+			t.Errorf("Error creating temporary directory: %s", err.Error())
+			return
+		}
+		err = files.WriteFile(sysConfDir+"/pg_service.conf", "[billing]\nhost=billing.example.com\nuser=billing\n")
+		if err != nil {
+			t.Errorf("Error writing service file: %s", err.Error())
+			return
+		}
+		t.Setenv("HOME", home)
+		t.Setenv("PGSYSCONFDIR", sysConfDir)
+		t.Setenv("PGSERVICE", "billing")
+		t.Setenv("PGPASSWORD", "s3cr3t")
+
+		config, err := LoadFromLibpqEnv()
+		if err != nil {
+			t.Errorf("Error loading configuration: %s", err.Error())
+			return
+		}
+		if config.Host != "billing.example.com" || config.Database != "billing" {
+			t.Errorf("The billing service expected")
+			return
+		}
+	})
+
+	// Test unknown service
+	t.Run("Test unknown service", func(t *testing.T) {
+		t.Setenv("HOME", home)
+		t.Setenv("PGSERVICE", "unknown")
+
+		_, err := LoadFromLibpqEnv()
+		if !errorex.IS(err, ErrorCodePgServiceNotFound) {
+			t.Errorf("PostgreSQL service not found error expected")
+			return
+		}
+	})
+
+	// Test invalid service file
+	t.Run("Test invalid service file", func(t *testing.T) {
+		serviceFile := home + "/invalid.conf"
+		if err := files.WriteFile(serviceFile, "[orders]\nhost\n"); err != nil {
+			t.Errorf("Error writing service file: %s", err.Error())
+			return
+		}
+		t.Setenv("PGSERVICEFILE", serviceFile)
+		t.Setenv("PGSERVICE", "orders")
+
+		_, err := LoadFromLibpqEnv()
+		if !errorex.IS(err, ErrorCodePgServiceParseError) {
+			t.Errorf("PostgreSQL service file parse error expected")
+			return
+		}
+	})
+
+	// Test load by libpq environment variables without password
+	t.Run("Test load by libpq environment variables without password", func(t *testing.T) {
+		t.Setenv("HOME", home)
+		t.Setenv("PGHOST", "localhost")
+		t.Setenv("PGUSER", "postgres")
+
+		// the trust and certificate authentications have no password
+		config, err := LoadFromLibpqEnv()
+		if err != nil {
+			t.Errorf("Error loading configuration: %s", err.Error())
+			return
+		}
+		if config.Host != "localhost" || config.User != "postgres" || config.Password != "" {
+			t.Errorf("The configuration without password expected, got %+v", config)
+			return
+		}
+	})
+
+	// Test load by libpq environment variables without host
+	t.Run("Test load by libpq environment variables without host", func(t *testing.T) {
+		if runtime.GOOS == "windows" {
+			t.Skip("Windows has no Unix-domain sockets")
+		}
+		t.Setenv("HOME", home)
+		t.Setenv("PGPORT", "5433")
+		t.Setenv("PGUSER", "postgres")
+
+		// the server socket selects the socket directory
+		socketDirs := []string{home + "/missing", home + "/tmp", home + "/run"}
+		for _, dir := range socketDirs[1:] {
+			if err := os.MkdirAll(dir, 0700); err != nil {
+				t.Errorf("Error creating socket directory: %s", err.Error())
+				return
+			}
+		}
+		if err := files.WriteFile(socketDirs[2]+"/.s.PGSQL.5433", ""); err != nil {
+			t.Errorf("Error writing socket file: %s", err.Error())
+			return
+		}
+		if err := files.WriteFile(socketDirs[1]+"/.s.PGSQL.5432", ""); err != nil {
+			t.Errorf("Error writing socket file: %s", err.Error())
+			return
+		}
+		defaultDirs := libpqSocketDirs
+		libpqSocketDirs = socketDirs
+		defer func() {
+			libpqSocketDirs = defaultDirs
+		}()
+
+		// the peer authentication has no password
+		config, err := LoadFromLibpqEnv()
+		if err != nil {
+			t.Errorf("Error loading configuration: %s", err.Error())
+			return
+		}
+		if config.Host != socketDirs[2] || config.Port != 5433 || config.Password != "" {
+			t.Errorf("The socket directory %s expected, got %+v", socketDirs[2], config)
+			return
+		}
+
+		// the first existing directory is used without the server socket
+		t.Setenv("PGPORT", "5434")
+		if config, err = LoadFromLibpqEnv(); err != nil || config.Host != socketDirs[1] {
+			t.Errorf("The socket directory %s expected, got %+v", socketDirs[1], config)
+			return
+		}
+
+		// the socket directory is given by the host and port URL parameters
+		dsn := config.PostgresDSN()
+		if dsn != "postgres://postgres@/postgres?host="+url.QueryEscape(socketDirs[1])+"&port=5434&sslmode=prefer" {
+			t.Errorf("The socket directory URL expected, got %s", dsn)
+			return
+		}
+		parsed, err := ParseURL(dsn)
+		if err != nil || !config.compare(parsed) {
+			t.Errorf("The configuration %+v expected, got %+v", config, parsed)
+			return
+		}
+	})
+
+	// Test libpq environment variables of the configuration
+	t.Run("Test libpq environment variables of the configuration", func(t *testing.T) {
+		config := Config{
+			Type:     DbTypePostgres,
+			Host:     "db.example.com",
+			Port:     5433,
+			User:     "app",
+			Password: "secret",
+			Database: "orders",
+			SSL:      &SSLConfig{Mode: SSLModeVerifyCA, Ca: "/etc/ssl/ca.crt"},
+		}
+		expected := []string{
+			"PGHOST=db.example.com",
+			"PGPORT=5433",
+			"PGDATABASE=orders",
+			"PGUSER=app",
+			"PGPASSWORD=secret",
+			"PGSSLMODE=verify-ca",
+			"PGSSLROOTCERT=/etc/ssl/ca.crt",
+		}
+		if vars := config.LibpqEnv(); !reflect.DeepEqual(vars, expected) {
+			t.Errorf("The variables %v expected, got %v", expected, vars)
+			return
+		}
+
+		// the variables must load back to the same configuration
+		for _, variable := range expected {
+			name, value, _ := strings.Cut(variable, "=")
+			t.Setenv(name, value)
+		}
+		loaded, err := LoadFromLibpqEnv()
+		if err != nil {
+			t.Errorf("Error loading configuration: %s", err.Error())
+			return
+		}
+		if !config.compare(loaded) {
+			t.Errorf("The loaded configuration is different from the original configuration")
+			return
+		}
+	})
+
+}
@@ -0,0 +1,414 @@
+/*
+ * © 2023 fkmatsuda
+
+ * This file is licensed under the terms of the MIT license. Permission is hereby
+ * granted, free of charge, to any person obtaining a copy of this software and
+ * associated documentation files (the "Software"), to deal in the Software without
+ * restriction, including without limitation the rights to use, copy, modify,
+ * merge, publish, and/or distribute copies of the Software, and to permit persons
+ * to whom the Software is furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS," WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY,
+ * WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+ * CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ *
+ */
+
+package dbconfig
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/fkmatsuda-dev/commons/errorex"
+)
+
+const (
+	mysqlOptionFileNotFound   = "MySQL option file not found"
+	mysqlOptionFileParseError = "MySQL option file parse error"
+	mysqlOptionFileWriteError = "MySQL option file write error"
+
+	// mysqlIncludeDepth limits the nesting of the !include and !includedir directives
+	mysqlIncludeDepth = 10
+)
+
+// mysqlOptionGroups are the option file groups read by the mysql client, the [mysql] group holds the options like
+// the database that mysqldump rejects in the [client] group
+var mysqlOptionGroups = []string{"client", "mysql"}
+
+// MysqlSSLModeValue maps the MySQL ssl-mode values to a SSLMode
+var MysqlSSLModeValue = map[string]SSLMode{
+	"DISABLED":        SSLModeDisable,
+	"PREFERRED":       SSLModePrefer,
+	"REQUIRED":        SSLModeRequire,
+	"VERIFY_CA":       SSLModeVerifyCA,
+	"VERIFY_IDENTITY": SSLModeVerifyFull,
+}
+
+// MysqlSSLModeName maps a SSLMode to the MySQL ssl-mode values, MySQL has no equivalent to the allow mode
+var MysqlSSLModeName = map[SSLMode]string{
+	SSLModeDisable:    "DISABLED",
+	SSLModeAllow:      "PREFERRED",
+	SSLModePrefer:     "PREFERRED",
+	SSLModeRequire:    "REQUIRED",
+	SSLModeVerifyCA:   "VERIFY_CA",
+	SSLModeVerifyFull: "VERIFY_IDENTITY",
+}
+
+// MysqlOptionFiles returns the default MySQL option files read by the client programs, in the reading order
+func MysqlOptionFiles() []string {
+	var optionFiles []string
+	if runtime.GOOS == "windows" {
+		for _, dir := range []string{os.Getenv("WINDIR"), `C:\`} {
+			optionFiles = append(optionFiles, filepath.Join(dir, "my.ini"), filepath.Join(dir, "my.cnf"))
+		}
+	} else {
+		optionFiles = append(optionFiles, "/etc/my.cnf", "/etc/mysql/my.cnf")
+	}
+	if home, err := os.UserHomeDir(); err == nil {
+		optionFiles = append(optionFiles, filepath.Join(home, ".my.cnf"))
+	}
+	return optionFiles
+}
+
+// LoadFromMysqlOptionFiles loads the MySQL settings from the [client] and [mysql] groups of the option files and
+// returns a struct Config, the files are read in order and the later values override the earlier ones, without files
+// the default files of MysqlOptionFiles are read. The !include and !includedir directives are followed, and the
+// password, host and port are read from the MYSQL_PWD, MYSQL_HOST and MYSQL_TCP_PORT environment variables when the
+// files do not set them. Like in the mysql client the database and the password are optional
+func LoadFromMysqlOptionFiles(optionFiles ...string) (Config, error) {
+	explicit := len(optionFiles) > 0
+	if !explicit {
+		optionFiles = MysqlOptionFiles()
+	}
+
+	options := make(map[string]string)
+	found := false
+	for _, optionFile := range optionFiles {
+		if _, err := os.Stat(optionFile); err != nil {
+			if explicit {
+				return Config{}, errorex.New(ErrorCodeMysqlOptionFileNotFound, mysqlOptionFileNotFound, err.Error())
+			}
+			continue
+		}
+		found = true
+		if err := parseMysqlOptionFile(optionFile, mysqlOptionGroups, options, 0); err != nil {
+			return Config{}, err
+		}
+	}
+	if !found {
+		return Config{}, errorex.New(
+			ErrorCodeMysqlOptionFileNotFound,
+			mysqlOptionFileNotFound,
+			fmt.Sprintf("none of %s exists", strings.Join(optionFiles, ", ")),
+		)
+	}
+
+	variables := map[string]string{"password": "MYSQL_PWD", "host": "MYSQL_HOST", "port": "MYSQL_TCP_PORT"}
+	for option, variable := range variables {
+		if _, ok := options[option]; ok {
+			continue
+		}
+		if value, chk := lookupEnv(variable); chk {
+			options[option] = value
+		}
+	}
+	return mysqlConfig(options)
+}
+
+// mysqlConfig converts the MySQL client options to a validated struct Config
+func mysqlConfig(options map[string]string) (Config, error) {
+	config := Config{
+		Type:     DbTypeMysql,
+		Host:     options["host"],
+		Port:     DbTypeMysql.DefaultPort(),
+		User:     options["user"],
+		Password: options["password"],
+		Database: options["database"],
+	}
+	if config.Host == "" {
+		config.Host = "localhost"
+	}
+	if strPort, ok := options["port"]; ok && strPort != "" {
+		port, err := strconv.ParseUint(strPort, 10, 16)
+		if err != nil {
+			return Config{}, errorex.New(
+				ErrorCodeMysqlOptionFileParseError,
+				mysqlOptionFileParseError,
+				fmt.Sprintf("\"%s\" value for port is invalid", strPort),
+			)
+		}
+		config.Port = uint16(port)
+	}
+
+	sslConfig := SSLConfig{Ca: options["ssl-ca"], Cert: options["ssl-cert"], Key: options["ssl-key"]}
+	if strSSLMode, ok := options["ssl-mode"]; ok {
+		sslMode, ok := MysqlSSLModeValue[strings.ToUpper(strSSLMode)]
+		if !ok {
+			return Config{}, errorex.New(
+				ErrorCodeMysqlOptionFileParseError,
+				mysqlOptionFileParseError,
+				fmt.Sprintf("\"%s\" value for ssl-mode is invalid", strSSLMode),
+			)
+		}
+		sslConfig.Mode = sslMode
+	} else if value, ok := options["ssl-verify-server-cert"]; ok && isMysqlTrue(value) {
+		sslConfig.Mode = SSLModeVerifyFull
+	} else if value, ok := options["ssl"]; ok {
+		sslConfig.Mode = SSLModeDisable
+		if isMysqlTrue(value) {
+			sslConfig.Mode = SSLModeRequire
+		}
+	}
+	if sslConfig.Mode != 0 {
+		config.SSL = &sslConfig
+	}
+
+	if err := config.validate("database", "password"); err != nil {
+		return Config{}, err
+	}
+	return config, nil
+}
+
+// isMysqlTrue checks if the value of a MySQL boolean option is true, the options without value are true
+func isMysqlTrue(value string) bool {
+	switch strings.ToLower(value) {
+	case "", "1", "on", "true":
+		return true
+	}
+	return false
+}
+
+// parseMysqlOptionFile reads the options of the groups from the option file into the options map
+func parseMysqlOptionFile(optionFile string, groups []string, options map[string]string, depth int) error {
+	if depth > mysqlIncludeDepth {
+		return errorex.New(
+			ErrorCodeMysqlOptionFileParseError,
+			mysqlOptionFileParseError,
+			fmt.Sprintf("too many nested includes in %s", optionFile),
+		)
+	}
+	file, err := os.Open(optionFile)
+	if err != nil {
+		return errorex.New(ErrorCodeMysqlOptionFileParseError, mysqlOptionFileParseError, err.Error())
+	}
+	defer func(file *os.File) {
+		_ = file.Close()
+	}(file)
+
+	inGroup := false
+	scanner := bufio.NewScanner(file)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case line == "" || line[0] == '#' || line[0] == ';':
+			continue
+		case strings.HasPrefix(line, "!include "):
+			include := resolveMysqlInclude(optionFile, strings.TrimSpace(strings.TrimPrefix(line, "!include ")))
+			if err := parseMysqlOptionFile(include, groups, options, depth+1); err != nil {
+				return err
+			}
+			continue
+		case strings.HasPrefix(line, "!includedir "):
+			dir := resolveMysqlInclude(optionFile, strings.TrimSpace(strings.TrimPrefix(line, "!includedir ")))
+			if err := parseMysqlOptionDir(dir, groups, options, depth+1); err != nil {
+				return err
+			}
+			continue
+		case line[0] == '[':
+			end := strings.IndexByte(line, ']')
+			if end < 0 {
+				return mysqlSyntaxError(optionFile, lineNumber)
+			}
+			inGroup = false
+			for _, group := range groups {
+				inGroup = inGroup || strings.EqualFold(strings.TrimSpace(line[1:end]), group)
+			}
+			continue
+		}
+		if !inGroup {
+			continue
+		}
+		name, value, err := parseMysqlOption(line)
+		if err != nil {
+			return mysqlSyntaxError(optionFile, lineNumber)
+		}
+		options[name] = value
+	}
+	if err := scanner.Err(); err != nil {
+		return errorex.New(ErrorCodeMysqlOptionFileParseError, mysqlOptionFileParseError, err.Error())
+	}
+	return nil
+}
+
+// parseMysqlOptionDir reads the option files of an !includedir directory in name order
+func parseMysqlOptionDir(dir string, groups []string, options map[string]string, depth int) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return errorex.New(ErrorCodeMysqlOptionFileParseError, mysqlOptionFileParseError, err.Error())
+	}
+	var names []string
+	for _, entry := range entries {
+		ext := strings.ToLower(filepath.Ext(entry.Name()))
+		if !entry.IsDir() && (ext == ".cnf" || (runtime.GOOS == "windows" && ext == ".ini")) {
+			names = append(names, entry.Name())
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if err := parseMysqlOptionFile(filepath.Join(dir, name), groups, options, depth); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// resolveMysqlInclude resolves the included path relative to the directory of the including file
+func resolveMysqlInclude(optionFile string, include string) string {
+	if filepath.IsAbs(include) {
+		return include
+	}
+	return filepath.Join(filepath.Dir(optionFile), include)
+}
+
+// parseMysqlOption parses an option line, the option names are normalized to dashes without the loose- prefix and
+// the values are unquoted and unescaped
+func parseMysqlOption(line string) (string, string, error) {
+	name, value, _ := strings.Cut(line, "=")
+	name = strings.ReplaceAll(strings.ToLower(strings.TrimSpace(name)), "_", "-")
+	name = strings.TrimPrefix(name, "loose-")
+	if name == "" {
+		return "", "", fmt.Errorf("option without name")
+	}
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return name, "", nil
+	}
+
+	if quote := value[0]; quote == '"' || quote == '\'' {
+		// the quoted value ends at the first quote that is not escaped, only a comment may follow it
+		for end := 1; end < len(value); end++ {
+			switch value[end] {
+			case '\\':
+				end++
+			case quote:
+				if rest := strings.TrimSpace(value[end+1:]); rest != "" && rest[0] != '#' {
+					return "", "", fmt.Errorf("unexpected characters after the quoted value")
+				}
+				return name, unescapeMysqlValue(value[1:end]), nil
+			}
+		}
+		return "", "", fmt.Errorf("unterminated quoted value")
+	}
+	// the comments may start in the middle of the unquoted values
+	if idx := strings.Index(value, "#"); idx >= 0 {
+		value = strings.TrimSpace(value[:idx])
+	}
+	return name, unescapeMysqlValue(value), nil
+}
+
+// unescapeMysqlValue replaces the escape sequences of the option values
+func unescapeMysqlValue(value string) string {
+	var builder strings.Builder
+	for i := 0; i < len(value); i++ {
+		if value[i] != '\\' || i+1 >= len(value) {
+			builder.WriteByte(value[i])
+			continue
+		}
+		i++
+		switch value[i] {
+		case 'b':
+			builder.WriteByte('\b')
+		case 't':
+			builder.WriteByte('\t')
+		case 'n':
+			builder.WriteByte('\n')
+		case 'r':
+			builder.WriteByte('\r')
+		case 's':
+			builder.WriteByte(' ')
+		case '\\', '"', '\'':
+			builder.WriteByte(value[i])
+		default:
+			// unknown escapes keep the backslash like MySQL does
+			builder.WriteByte('\\')
+			builder.WriteByte(value[i])
+		}
+	}
+	return builder.String()
+}
+
+func mysqlSyntaxError(optionFile string, lineNumber int) error {
+	return errorex.New(
+		ErrorCodeMysqlOptionFileParseError,
+		mysqlOptionFileParseError,
+		fmt.Sprintf("syntax error in option file \"%s\", line %d", optionFile, lineNumber),
+	)
+}
+
+// MysqlOptions returns the [client] group of a MySQL option file with the configuration settings, the database is
+// written to the [mysql] group read only by the mysql client because mysqldump rejects it in the [client] group
+func (c Config) MysqlOptions() string {
+	var builder strings.Builder
+	builder.WriteString("[client]\n")
+	// the line breaks are escaped so a value cannot end its line and add other options
+	escaper := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\r", `\r`, "\t", `\t`)
+	writeOption := func(name string, value string) {
+		if value != "" {
+			builder.WriteString(fmt.Sprintf("%s=\"%s\"\n", name, escaper.Replace(value)))
+		}
+	}
+	writeOption("host", c.Host)
+	if c.Port != 0 {
+		writeOption("port", strconv.Itoa(int(c.Port)))
+	}
+	writeOption("user", c.User)
+	writeOption("password", c.Password)
+	if c.SSL != nil {
+		writeOption("ssl-mode", MysqlSSLModeName[c.SSL.Mode])
+		writeOption("ssl-ca", c.SSL.Ca)
+		writeOption("ssl-cert", c.SSL.Cert)
+		writeOption("ssl-key", c.SSL.Key)
+	}
+	if c.Database != "" {
+		builder.WriteString("\n[mysql]\n")
+		writeOption("database", c.Database)
+	}
+	return builder.String()
+}
+
+// WriteMysqlOptionFile writes the MySQL option file of the configuration to the file with the 0600 mode
+func WriteMysqlOptionFile(file string, config Config) error {
+	if err := writeSecretFile(file, []byte(config.MysqlOptions())); err != nil {
+		return errorex.New(ErrorCodeMysqlOptionFileWriteError, mysqlOptionFileWriteError, err.Error())
+	}
+	return nil
+}
+
+// WriteTempMysqlOptionFile writes the MySQL option file of the configuration to a new temporary file with the 0600
+// mode and returns its name, the caller must remove the file, pass it to mysql and mysqldump with
+// --defaults-extra-file as their first argument
+func WriteTempMysqlOptionFile(config Config) (string, error) {
+	file, err := os.CreateTemp("", "dbconfig-*.cnf")
+	if err != nil {
+		return "", errorex.New(ErrorCodeMysqlOptionFileWriteError, mysqlOptionFileWriteError, err.Error())
+	}
+	_ = file.Close()
+	if err := WriteMysqlOptionFile(file.Name(), config); err != nil {
+		_ = os.Remove(file.Name())
+		return "", err
+	}
+	return file.Name(), nil
+}
@@ -0,0 +1,307 @@
+/*
+ * © 2023 fkmatsuda
+
+ * This file is licensed under the terms of the MIT license. Permission is hereby
+ * granted, free of charge, to any person obtaining a copy of this software and
+ * associated documentation files (the "Software"), to deal in the Software without
+ * restriction, including without limitation the rights to use, copy, modify,
+ * merge, publish, and/or distribute copies of the Software, and to permit persons
+ * to whom the Software is furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS," WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY,
+ * WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+ * CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ *
+ */
+
+package dbconfig
+
+import (
+	"os"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/fkmatsuda-dev/commons/errorex"
+	"github.com/fkmatsuda-dev/commons/files"
+)
+
+func TestMysqlOptionFiles(t *testing.T) {
+
+	// Create a temporary directory inside system temp directory
+	dirName, err := files.CreateTempDir()
+	if err != nil {
+		t.Errorf("Error creating temporary directory: %s", err.Error())
+		return
+	}
+	defer func() {
+		_ = files.CleanupTempDirs()
+	}()
+
+	if err := os.Mkdir(dirName+"/conf.d", 0755); err != nil {
+		t.Errorf("Error creating include directory: %s", err.Error())
+		return
+	}
+	optionFile := dirName + "/my.cnf"
+	err = files.WriteFile(optionFile, `# main option file
+[mysqld]
+port=3307
+
+[client]
+host = db.example.com
+port = 3306 # default port
+user=app
+loose_ssl_mode=VERIFY_CA
+ssl-ca="/etc/mysql/ca.pem"
+
+!include extra.cnf
+!includedir conf.d
+`)
+	if err == nil {
+		err = files.WriteFile(dirName+"/extra.cnf", "[client]\ndatabase=orders\n")
+	}
+	if err == nil {
+		err = files.WriteFile(dirName+"/conf.d/10-password.cnf", "[client]\npassword='s3\\\"cr#t'\n")
+	}
+	if err == nil {
+		err = files.WriteFile(dirName+"/conf.d/ignored.txt", "[client]\nuser=ignored\n")
+	}
+	if err != nil {
+		t.Errorf("Error writing option files: %s", err.Error())
+		return
+	}
+
+	// Test load option files
+	t.Run("Test load option files", func(t *testing.T) {
+		expected := Config{
+			Type:     DbTypeMysql,
+			Host:     "db.example.com",
+			Port:     3306,
+			User:     "app",
+			Password: `s3"cr#t`,
+			Database: "orders",
+			SSL: &SSLConfig{
+				Mode: SSLModeVerifyCA,
+				Ca:   "/etc/mysql/ca.pem",
+			},
+		}
+
+		config, err := LoadFromMysqlOptionFiles(optionFile)
+		if err != nil {
+			t.Errorf("Error loading option files: %s", err.Error())
+			return
+		}
+		if !expected.compare(config) {
+			t.Errorf("The loaded configuration is different from the expected configuration")
+			return
+		}
+	})
+
+	// Test later option files override earlier ones
+	t.Run("Test later option files override earlier ones", func(t *testing.T) {
+		override := dirName + "/override.cnf"
+		if err := files.WriteFile(override, "[client]\nuser=admin\n"); err != nil {
+			t.Errorf("Error writing option file: %s", err.Error())
+			return
+		}
+
+		config, err := LoadFromMysqlOptionFiles(optionFile, override)
+		if err != nil {
+			t.Errorf("Error loading option files: %s", err.Error())
+			return
+		}
+		if config.User != "admin" {
+			t.Errorf("The user of the last option file expected")
+			return
+		}
+	})
+
+	// Test MYSQL_PWD environment variable
+	t.Run("Test MYSQL_PWD environment variable", func(t *testing.T) {
+		withoutPassword := dirName + "/without-password.cnf"
+		err := files.WriteFile(withoutPassword, "[client]\nuser=app\ndatabase=orders\n")
+		if err != nil {
+			t.Errorf("Error writing option file: %s", err.Error())
+			return
+		}
+		t.Setenv("MYSQL_PWD", "from-env")
+
+		config, err := LoadFromMysqlOptionFiles(withoutPassword)
+		if err != nil {
+			t.Errorf("Error loading option files: %s", err.Error())
+			return
+		}
+		if config.Password != "from-env" || config.Host != "localhost" {
+			t.Errorf("The MYSQL_PWD password and the localhost host expected")
+			return
+		}
+	})
+
+	// Test missing option file
+	t.Run("Test missing option file", func(t *testing.T) {
+		_, err := LoadFromMysqlOptionFiles(dirName + "/missing.cnf")
+		if !errorex.IS(err, ErrorCodeMysqlOptionFileNotFound) {
+			t.Errorf("MySQL option file not found error expected")
+			return
+		}
+	})
+
+	// Test invalid option file
+	t.Run("Test invalid option file", func(t *testing.T) {
+		invalid := dirName + "/invalid.cnf"
+		if err := files.WriteFile(invalid, "[client\nuser=app\n"); err != nil {
+			t.Errorf("Error writing option file: %s", err.Error())
+			return
+		}
+		_, err := LoadFromMysqlOptionFiles(invalid)
+		if !errorex.IS(err, ErrorCodeMysqlOptionFileParseError) {
+			t.Errorf("MySQL option file parse error expected")
+			return
+		}
+	})
+
+	// Test option file without database and password
+	t.Run("Test option file without database and password", func(t *testing.T) {
+		t.Setenv("MYSQL_PWD", "")
+		file := dirName + "/socket.cnf"
+		if err := files.WriteFile(file, "[client]\nuser=app\n\n[mysql]\nhost=db.example.com\n"); err != nil {
+			t.Errorf("Error writing option file: %s", err.Error())
+			return
+		}
+		expected := Config{
+			Type: DbTypeMysql,
+			Host: "db.example.com",
+			Port: 3306,
+			User: "app",
+		}
+		config, err := LoadFromMysqlOptionFiles(file)
+		if err != nil {
+			t.Errorf("Error loading option file: %s", err.Error())
+			return
+		}
+		if !expected.compare(config) {
+			t.Errorf("The configuration %+v expected, got %+v", expected, config)
+			return
+		}
+	})
+
+	// Test write option file
+	t.Run("Test write option file", func(t *testing.T) {
+		config := Config{
+			Type:     DbTypeMysql,
+			Host:     "db.example.com",
+			Port:     3306,
+			User:     "app",
+			Password: `s3"cr\t#`,
+			Database: "orders",
+			SSL: &SSLConfig{
+				Mode: SSLModeVerifyFull,
+				Ca:   "/etc/mysql/ca.pem",
+			},
+		}
+
+		file, err := WriteTempMysqlOptionFile(config)
+		if err != nil {
+			t.Errorf("Error writing option file: %s", err.Error())
+			return
+		}
+		defer func() {
+			_ = os.Remove(file)
+		}()
+
+		info, err := os.Stat(file)
+		if err != nil || info.Mode().Perm() != 0600 {
+			t.Errorf("The option file mode must be 0600")
+			return
+		}
+		// an existing file is replaced by a 0600 file
+		existing := dirName + "/existing.cnf"
+		if err := os.WriteFile(existing, []byte("[client]\nuser=old\n"), 0644); err != nil {
+			t.Errorf("Error writing option file: %s", err.Error())
+			return
+		}
+		if err := WriteMysqlOptionFile(existing, config); err != nil {
+			t.Errorf("Error writing option file: %s", err.Error())
+			return
+		}
+		info, err = os.Stat(existing)
+		if err != nil || runtime.GOOS != "windows" && info.Mode().Perm() != 0600 {
+			t.Errorf("The existing option file mode must be 0600")
+			return
+		}
+		if !strings.HasSuffix(config.MysqlOptions(), "\n[mysql]\ndatabase=\"orders\"\n") {
+			t.Errorf("The database must be written to the [mysql] group")
+			return
+		}
+
+		// the written options must be read back with the database of the [mysql] group
+		loaded, err := LoadFromMysqlOptionFiles(file)
+		if err != nil {
+			t.Errorf("Error loading option file: %s", err.Error())
+			return
+		}
+		if !config.compare(loaded) {
+			t.Errorf("The loaded configuration is different from the written configuration")
+			return
+		}
+	})
+
+	// Test write option file with line breaks
+	t.Run("Test write option file with line breaks", func(t *testing.T) {
+		config := Config{
+			Type:     DbTypeMysql,
+			Host:     "db.example.com",
+			Port:     3306,
+			User:     "app",
+			Password: "s3cr3t\"\nhost=attacker.example.com\r\n\tuser=root",
+			Database: "orders",
+		}
+		options := config.MysqlOptions()
+		if strings.Contains(options, "\nhost=attacker") {
+			t.Errorf("The password must not add options\n%s", options)
+			return
+		}
+		file := dirName + "/line-breaks.cnf"
+		if err := WriteMysqlOptionFile(file, config); err != nil {
+			t.Errorf("Error writing option file: %s", err.Error())
+			return
+		}
+		loaded, err := LoadFromMysqlOptionFiles(file)
+		if err != nil {
+			t.Errorf("Error loading option file: %s", err.Error())
+			return
+		}
+		if !config.compare(loaded) {
+			t.Errorf("The configuration %+v expected, got %+v", config, loaded)
+			return
+		}
+	})
+
+	// Test quoted values with comments
+	t.Run("Test quoted values with comments", func(t *testing.T) {
+		values := map[string]string{
+			`password="a" # "comment"`:   "a",
+			`password='a\'b' # it's`:     "a'b",
+			`password="a#b"`:             "a#b",
+			`password="a\\" # "comment"`: `a\`,
+		}
+		for line, expected := range values {
+			_, value, err := parseMysqlOption(line)
+			if err != nil || value != expected {
+				t.Errorf("The value %q expected for %s, got %q (%v)", expected, line, value, err)
+			}
+		}
+		for _, line := range []string{`password="a`, `password="a" b`, `password="a\"`} {
+			if _, _, err := parseMysqlOption(line); err == nil {
+				t.Errorf("Error expected for %s", line)
+			}
+		}
+	})
+
+}
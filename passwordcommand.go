@@ -0,0 +1,107 @@
+/*
+ * © 2023 fkmatsuda
+
+ * This file is licensed under the terms of the MIT license. Permission is hereby
+ * granted, free of charge, to any person obtaining a copy of this software and
+ * associated documentation files (the "Software"), to deal in the Software without
+ * restriction, including without limitation the rights to use, copy, modify,
+ * merge, publish, and/or distribute copies of the Software, and to permit persons
+ * to whom the Software is furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS," WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY,
+ * WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+ * CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ *
+ */
+
+package dbconfig
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/fkmatsuda-dev/commons/errorex"
+)
+
+var (
+	// PasswordCommandTimeout is the maximum time a password command may run
+	PasswordCommandTimeout = 10 * time.Second
+	// passwordCommandStderrLimit is the maximum number of stderr bytes kept in the error detail
+	passwordCommandStderrLimit = 512
+)
+
+const (
+	passwordCommandFailed  = "Password command failed"
+	passwordCommandTimeout = "Password command timed out"
+)
+
+// runPasswordCommand runs the password command with the system shell and returns its trimmed stdout,
+// the stdout is never included in the returned errors
+func runPasswordCommand(command string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), PasswordCommandTimeout)
+	defer cancel()
+
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.CommandContext(ctx, "cmd", "/C", command)
+	} else {
+		cmd = exec.CommandContext(ctx, "sh", "-c", command)
+	}
+	// do not wait for the orphaned children holding the output pipes after the timeout
+	cmd.WaitDelay = time.Second
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	err := cmd.Run()
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return "", errorex.New(
+			ErrorCodePasswordCommandTimeout,
+			passwordCommandTimeout,
+			fmt.Sprintf("password command did not finish in %s", PasswordCommandTimeout),
+		)
+	}
+	if err != nil {
+		detail := err.Error()
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			detail = fmt.Sprintf("password command exited with code %d", exitErr.ExitCode())
+		}
+		if msg := commandStderr(stderr.String()); msg != "" {
+			detail = fmt.Sprintf("%s: %s", detail, msg)
+		}
+		return "", errorex.New(ErrorCodePasswordCommandFailed, passwordCommandFailed, detail)
+	}
+
+	password := strings.TrimSpace(stdout.String())
+	if password == "" {
+		return "", errorex.New(
+			ErrorCodePasswordCommandFailed,
+			passwordCommandFailed,
+			"password command returned an empty output",
+		)
+	}
+	return password, nil
+}
+
+// commandStderr trims the stderr output of a command to the error detail limit
+func commandStderr(stderr string) string {
+	stderr = strings.TrimSpace(stderr)
+	if len(stderr) > passwordCommandStderrLimit {
+		stderr = stderr[:passwordCommandStderrLimit] + "..."
+	}
+	return stderr
+}
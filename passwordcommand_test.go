@@ -0,0 +1,218 @@
+/*
+ * © 2023 fkmatsuda
+
+ * This file is licensed under the terms of the MIT license. Permission is hereby
+ * granted, free of charge, to any person obtaining a copy of this software and
+ * associated documentation files (the "Software"), to deal in the Software without
+ * restriction, including without limitation the rights to use, copy, modify,
+ * merge, publish, and/or distribute copies of the Software, and to permit persons
+ * to whom the Software is furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS," WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY,
+ * WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+ * CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ *
+ */
+
+package dbconfig
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fkmatsuda-dev/commons/errorex"
+	"github.com/fkmatsuda-dev/commons/files"
+)
+
+func TestPasswordCommand(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("The password command tests use shell scripts")
+	}
+
+	// Create a temporary directory inside system temp directory
+	dirName, err := files.CreateTempDir()
+	if err != nil {
+		t.Errorf("Error creating temporary directory: %s", err.Error())
+		return
+	}
+	defer func() {
+		_ = files.CleanupTempDirs()
+	}()
+
+	// writeScript writes an executable shell script and returns its path
+	writeScript := func(t *testing.T, name string, content string) string {
+		script := dirName + "/" + name
+		if err := os.WriteFile(script, []byte("#!/bin/sh\n"+content+"\n"), 0700); err != nil {
+			t.Fatalf("Error writing script: %s", err.Error())
+		}
+		return script
+	}
+
+	passwordScript := writeScript(t, "password.sh", "printf '  s3cr3t\\n'")
+
+	// Test password command output
+	t.Run("Test password command output", func(t *testing.T) {
+		password, err := runPasswordCommand(passwordScript)
+		if err != nil {
+			t.Errorf("Error running password command: %s", err.Error())
+			return
+		}
+		if password != "s3cr3t" {
+			t.Errorf("The trimmed password command output expected")
+			return
+		}
+	})
+
+	// Test password command with non-zero exit
+	t.Run("Test password command with non-zero exit", func(t *testing.T) {
+		script := writeScript(t, "fail.sh", "echo s3cr3t\necho 'vault is sealed' >&2\nexit 3")
+
+		_, err := runPasswordCommand(script)
+		if !errorex.IS(err, ErrorCodePasswordCommandFailed) {
+			t.Errorf("Password command failed error expected")
+			return
+		}
+		detail := err.(errorex.EX).Detail()
+		if !strings.Contains(detail, "code 3") || !strings.Contains(detail, "vault is sealed") {
+			t.Errorf("The exit code and stderr expected in the error detail: %s", detail)
+			return
+		}
+		if strings.Contains(detail, "s3cr3t") {
+			t.Errorf("The password command output must not be included in the error detail")
+			return
+		}
+	})
+
+	// Test password command with empty output
+	t.Run("Test password command with empty output", func(t *testing.T) {
+		_, err := runPasswordCommand("true")
+		if !errorex.IS(err, ErrorCodePasswordCommandFailed) {
+			t.Errorf("Password command failed error expected")
+			return
+		}
+	})
+
+	// Test password command timeout
+	t.Run("Test password command timeout", func(t *testing.T) {
+		timeout := PasswordCommandTimeout
+		PasswordCommandTimeout = 100 * time.Millisecond
+		defer func() {
+			PasswordCommandTimeout = timeout
+		}()
+
+		_, err := runPasswordCommand("sleep 5")
+		if !errorex.IS(err, ErrorCodePasswordCommandTimeout) {
+			t.Errorf("Password command timeout error expected")
+			return
+		}
+	})
+
+	// Test passwordCommand in configuration file
+	t.Run("Test passwordCommand in configuration file", func(t *testing.T) {
+		err := files.WriteFile(dirName+"/dbconfig.json", `{
+			"type": "POSTGRESQL",
+			"host": "localhost",
+			"port": 5432,
+			"user": "postgres",
+			"passwordCommand": "`+passwordScript+`",
+			"database": "postgres"
+		}`)
+		if err != nil {
+			t.Errorf("Error writing json configuration file: %s", err.Error())
+			return
+		}
+		defer func() {
+			_ = os.Remove(dirName + "/dbconfig.json")
+		}()
+
+		config, err := LoadConfig(dirName)
+		if err != nil {
+			t.Errorf("Error loading configuration: %s", err.Error())
+			return
+		}
+		if config.Password != "s3cr3t" {
+			t.Errorf("The password must be read from the password command")
+			return
+		}
+	})
+
+	// Test DB_PASSWORD_COMMAND environment variable
+	t.Run("Test DB_PASSWORD_COMMAND environment variable", func(t *testing.T) {
+		t.Setenv("DB_TYPE", "POSTGRESQL")
+		t.Setenv("DB_HOST", "localhost")
+		t.Setenv("DB_USER", "postgres")
+		t.Setenv("DB_DATABASE", "postgres")
+		t.Setenv("DB_PASSWORD_COMMAND", passwordScript)
+
+		config, err := LoadFromEnv()
+		if err != nil {
+			t.Errorf("Error loading configuration: %s", err.Error())
+			return
+		}
+		if config.Password != "s3cr3t" {
+			t.Errorf("The password must be read from the password command")
+			return
+		}
+
+		// DB_PASSWORD takes precedence over DB_PASSWORD_COMMAND
+		t.Setenv("DB_PASSWORD", "from-env")
+		config, err = LoadFromEnv()
+		if err != nil {
+			t.Errorf("Error loading configuration: %s", err.Error())
+			return
+		}
+		if config.Password != "from-env" {
+			t.Errorf("The password must be read from the DB_PASSWORD environment variable")
+			return
+		}
+	})
+
+	// Test DB_PASSWORD_COMMAND with the password of other sources
+	t.Run("Test DB_PASSWORD_COMMAND with the password of other sources", func(t *testing.T) {
+		t.Setenv("DB_TYPE", "POSTGRESQL")
+		t.Setenv("DB_HOST", "localhost")
+		t.Setenv("DB_USER", "postgres")
+		t.Setenv("DB_DATABASE", "postgres")
+		t.Setenv("DB_PASSWORD_COMMAND", passwordScript)
+
+		// the secret payload password takes precedence over DB_PASSWORD_COMMAND
+		t.Setenv("DB_SECRET_JSON", `{"password": "from-secret"}`)
+		config, err := LoadFromEnv()
+		if err != nil {
+			t.Errorf("Error loading configuration: %s", err.Error())
+			return
+		}
+		if config.Password != "from-secret" {
+			t.Errorf("The password must be read from the secret payload, got %q", config.Password)
+			return
+		}
+
+		// the db-password systemd credential takes precedence over DB_PASSWORD_COMMAND
+		credentialsDir := t.TempDir()
+		if err := os.WriteFile(filepath.Join(credentialsDir, "db-password"), []byte("from-credential"), 0600); err != nil {
+			t.Errorf("Error writing credential: %s", err.Error())
+			return
+		}
+		t.Setenv("CREDENTIALS_DIRECTORY", credentialsDir)
+		config, err = LoadFromEnv()
+		if err != nil {
+			t.Errorf("Error loading configuration: %s", err.Error())
+			return
+		}
+		if config.Password != "from-credential" {
+			t.Errorf("The password must be read from the systemd credential, got %q", config.Password)
+			return
+		}
+	})
+
+}
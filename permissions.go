@@ -0,0 +1,252 @@
+/*
+ * © 2023 fkmatsuda
+
+ * This file is licensed under the terms of the MIT license. Permission is hereby
+ * granted, free of charge, to any person obtaining a copy of this software and
+ * associated documentation files (the "Software"), to deal in the Software without
+ * restriction, including without limitation the rights to use, copy, modify,
+ * merge, publish, and/or distribute copies of the Software, and to permit persons
+ * to whom the Software is furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS," WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY,
+ * WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+ * CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ *
+ */
+
+package dbconfig
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/fkmatsuda-dev/commons/errorex"
+)
+
+type PermissionCheck int8
+
+const (
+	PermissionCheckOff PermissionCheck = iota + 1
+	PermissionCheckWarn
+	PermissionCheckFail
+)
+
+var PermissionCheckName = map[PermissionCheck]string{
+	PermissionCheckOff:  "off",
+	PermissionCheckWarn: "warn",
+	PermissionCheckFail: "fail",
+}
+
+var PermissionCheckValue = map[string]PermissionCheck{
+	PermissionCheckName[PermissionCheckOff]:  PermissionCheckOff,
+	PermissionCheckName[PermissionCheckWarn]: PermissionCheckWarn,
+	PermissionCheckName[PermissionCheckFail]: PermissionCheckFail,
+}
+
+// String returns the string value of the PermissionCheck
+func (s PermissionCheck) String() string {
+	return PermissionCheckName[s]
+}
+
+// ParsePermissionCheck parses the string value to a PermissionCheck
+func ParsePermissionCheck(value string) (PermissionCheck, error) {
+	if s, ok := PermissionCheckValue[value]; ok {
+		return s, nil
+	}
+	return PermissionCheck(0), errorex.New(
+		ErrorCodePermissionCheckParseError,
+		"PermissionCheck parse error",
+		fmt.Sprintf("\"%s\" value for PermissionCheck is invalid",
+			value,
+		),
+	)
+}
+
+// MarshalJSON marshals the enum as a quoted json string
+func (s PermissionCheck) MarshalJSON() ([]byte, error) {
+	return json.Marshal(s.String())
+}
+
+// UnmarshalJSON unmashals a quoted json string to the enum value
+func (s *PermissionCheck) UnmarshalJSON(data []byte) error {
+	var value string
+	if err := json.Unmarshal(data, &value); err != nil {
+		return errorex.New(ErrorCodePermissionCheckParseError, "PermissionCheck parse error", err.Error())
+	}
+	parsed, err := ParsePermissionCheck(value)
+	if err != nil {
+		return err
+	}
+	*s = parsed
+	return nil
+}
+
+const (
+	insecureConfigFile = "Insecure configuration file permissions"
+	insecureKeyFile    = "Insecure SSL key file permissions"
+	insecureCertFile   = "Insecure SSL certificate file permissions"
+	insecureFileOwner  = "Insecure file ownership"
+)
+
+// CheckPermissions checks the mode and the ownership of the configuration file and of the SSL files referenced by
+// the configuration, following the libpq rules for .pgpass and the SSL keys:
+// the configuration file holding a plaintext password and the SSL key must not be accessible by the group or by
+// others (a root owned key may be readable by the group), the SSL certificates must not be writable by the group or
+// by others, and all of them must be owned by the current user or by root.
+// An empty configFile only checks the SSL files, the files that do not exist are ignored
+func CheckPermissions(configFile string, config Config) []error {
+	plaintextPassword := false
+	if content, err := os.ReadFile(configFile); configFile != "" && err == nil {
+		document, err := decodeConfigDocument(configFile, content)
+		plaintextPassword = err == nil && hasPlaintextPassword(document)
+	}
+	return checkPermissions(configFile, plaintextPassword, config)
+}
+
+// checkPermissions checks the permissions like CheckPermissions, plaintextPassword tells whether the configuration
+// file holds a plaintext password
+func checkPermissions(configFile string, plaintextPassword bool, config Config) []error {
+	if runtime.GOOS == "windows" {
+		return nil
+	}
+	var violations []error
+	if configFile != "" && plaintextPassword {
+		violations = append(violations, checkFile(configFile, 0077, 0077, ErrorCodeInsecureConfigFile, insecureConfigFile)...)
+	}
+	// libpq ignores the password file when it is accessible by the group or by others
+	if pgpassFile := PgpassFile(); config.Type.usesPgpass() && pgpassFile != "" {
+		violations = append(violations, checkFile(pgpassFile, 0077, 0077, ErrorCodeInsecurePgpassFile, insecurePgpassFile)...)
+	}
+	if config.SSL != nil {
+		if config.SSL.Key != "" {
+			violations = append(violations, checkFile(config.SSL.Key, 0077, 0037, ErrorCodeInsecureKeyFile, insecureKeyFile)...)
+		}
+		for _, certFile := range []string{config.SSL.Cert, config.SSL.Ca} {
+			if certFile != "" {
+				violations = append(violations, checkFile(certFile, 0022, 0022, ErrorCodeInsecureCertFile, insecureCertFile)...)
+			}
+		}
+	}
+	return violations
+}
+
+// checkFile checks that the file mode has none of the forbidden bits, rootForbidden is used for the files owned by
+// root, and that the file is owned by the current user or by root
+func checkFile(file string, forbidden os.FileMode, rootForbidden os.FileMode, code string, message string) []error {
+	info, err := os.Stat(file)
+	if err != nil {
+		return nil
+	}
+	var violations []error
+	uid, hasOwner := fileOwner(info)
+	if hasOwner && uid == 0 {
+		forbidden = rootForbidden
+	}
+	if mode := info.Mode().Perm(); mode&forbidden != 0 {
+		violations = append(violations, errorex.New(
+			code,
+			message,
+			fmt.Sprintf("%s has mode %04o, it must not have any of the %04o permissions", file, mode, forbidden),
+		))
+	}
+	if currentUID := os.Getuid(); hasOwner && uid != 0 && uid != currentUID {
+		violations = append(violations, errorex.New(
+			ErrorCodeInsecureFileOwner,
+			insecureFileOwner,
+			fmt.Sprintf("%s is owned by uid %d, it must be owned by uid %d or by root", file, uid, currentUID),
+		))
+	}
+	return violations
+}
+
+// hasPlaintextPassword checks if the decoded configuration document holds a password that is not encrypted, it must be
+// called before the values are decrypted
+func hasPlaintextPassword(document interface{}) bool {
+	if isSopsDocument(document) {
+		return false
+	}
+	root, ok := document.(map[string]interface{})
+	if !ok {
+		return false
+	}
+	for key, value := range root {
+		if password, ok := value.(string); ok && strings.EqualFold(key, "password") && password != "" {
+			return !IsEncrypted(password)
+		}
+	}
+	return false
+}
+
+// applyPermissionCheck checks the permissions of the loaded files according to the options, or to the
+// DBCONFIG_PERMISSION_CHECK environment variable when the options do not set it, the checks are off by default
+func applyPermissionCheck(configFile string, plaintextPassword bool, config Config, options LoadOptions) error {
+	mode := options.PermissionCheck
+	if mode == 0 {
+		mode = PermissionCheckOff
+		if value, chk := lookupEnv("DBCONFIG_PERMISSION_CHECK"); chk {
+			parsed, err := ParsePermissionCheck(value)
+			if err != nil {
+				return err
+			}
+			mode = parsed
+		}
+	}
+	if mode == PermissionCheckOff {
+		return nil
+	}
+
+	violations := checkPermissions(configFile, plaintextPassword, config)
+	if mode == PermissionCheckFail && len(violations) > 0 {
+		return violations[0]
+	}
+	warn := options.PermissionWarning
+	if warn == nil {
+		warn = logPermissionWarning
+	}
+	for _, violation := range violations {
+		warn(violation)
+	}
+	return nil
+}
+
+// logPermissionWarning logs the permission violation with the standard logger, it is only used when the warn mode is
+// selected without a PermissionWarning function
+func logPermissionWarning(violation error) {
+	if ex, ok := violation.(errorex.EX); ok {
+		log.Printf("dbconfig: warning: %s: %s", ex.Error(), ex.Detail())
+		return
+	}
+	log.Printf("dbconfig: warning: %s", violation.Error())
+}
+
+// writeSecretFile writes the content to a new 0600 temporary file in the directory of the file and renames it over the
+// file, so the content is never written with the mode of an existing file and a failed write keeps the existing file
+func writeSecretFile(file string, content []byte) error {
+	temp, err := os.CreateTemp(filepath.Dir(file), "."+filepath.Base(file)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	// the temporary file no longer exists after the rename
+	defer func() {
+		_ = os.Remove(temp.Name())
+	}()
+	if _, err := temp.Write(content); err != nil {
+		_ = temp.Close()
+		return err
+	}
+	if err := temp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(temp.Name(), file)
+}
@@ -0,0 +1,262 @@
+/*
+ * © 2023 fkmatsuda
+
+ * This file is licensed under the terms of the MIT license. Permission is hereby
+ * granted, free of charge, to any person obtaining a copy of this software and
+ * associated documentation files (the "Software"), to deal in the Software without
+ * restriction, including without limitation the rights to use, copy, modify,
+ * merge, publish, and/or distribute copies of the Software, and to permit persons
+ * to whom the Software is furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS," WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY,
+ * WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+ * CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ *
+ */
+
+package dbconfig
+
+import (
+	"os"
+	"runtime"
+	"testing"
+
+	"github.com/fkmatsuda-dev/commons/errorex"
+	"github.com/fkmatsuda-dev/commons/files"
+)
+
+func TestPermissionChecks(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("The permission checks are not available on windows")
+	}
+
+	// Create a temporary directory inside system temp directory
+	dirName, err := files.CreateTempDir()
+	if err != nil {
+		t.Errorf("Error creating temporary directory: %s", err.Error())
+		return
+	}
+	defer func() {
+		_ = files.CleanupTempDirs()
+	}()
+
+	configFile := dirName + "/dbconfig.json"
+	keyFile := dirName + "/client.key"
+	writeFile := func(t *testing.T, file string, content string, mode os.FileMode) {
+		if err := os.WriteFile(file, []byte(content), mode); err != nil {
+			t.Fatalf("Error writing file: %s", err.Error())
+		}
+		if err := os.Chmod(file, mode); err != nil {
+			t.Fatalf("Error changing file mode: %s", err.Error())
+		}
+	}
+	writeConfig := func(t *testing.T, password string, mode os.FileMode) {
+		writeFile(t, configFile, `{
+			"type": "POSTGRESQL",
+			"host": "localhost",
+			"port": 5432,
+			"user": "postgres",
+			"password": "`+password+`",
+			"database": "postgres",
+			"ssl": {
+				"mode": "verify-full",
+				"ca": "`+dirName+`/ca.crt",
+				"key": "`+keyFile+`",
+				"cert": "`+dirName+`/client.crt"
+			}
+		}`, mode)
+	}
+	writeFile(t, keyFile, "key", 0600)
+	t.Setenv("PGPASSFILE", dirName+"/pgpass")
+
+	// Test parse permission check
+	t.Run("Test parse permission check", func(t *testing.T) {
+		mode, err := ParsePermissionCheck("fail")
+		if err != nil || mode != PermissionCheckFail {
+			t.Errorf("PermissionCheckFail expected")
+			return
+		}
+		_, err = ParsePermissionCheck("invalid")
+		if !errorex.IS(err, ErrorCodePermissionCheckParseError) {
+			t.Errorf("PermissionCheck parse error expected")
+			return
+		}
+	})
+
+	// Test secure files
+	t.Run("Test secure files", func(t *testing.T) {
+		writeConfig(t, "postgres", 0600)
+		_, err := LoadConfigWithOptions(dirName, LoadOptions{PermissionCheck: PermissionCheckFail})
+		if err != nil {
+			t.Errorf("Error loading configuration: %s", err.Error())
+			return
+		}
+	})
+
+	// Test world readable configuration file with password
+	t.Run("Test world readable configuration file with password", func(t *testing.T) {
+		writeConfig(t, "postgres", 0644)
+		_, err := LoadConfigWithOptions(dirName, LoadOptions{PermissionCheck: PermissionCheckFail})
+		if !errorex.IS(err, ErrorCodeInsecureConfigFile) {
+			t.Errorf("Insecure configuration file error expected")
+			return
+		}
+
+		// DBCONFIG_PERMISSION_CHECK sets the mode when the options do not
+		t.Setenv("DBCONFIG_PERMISSION_CHECK", "fail")
+		_, err = LoadConfig(dirName)
+		if !errorex.IS(err, ErrorCodeInsecureConfigFile) {
+			t.Errorf("Insecure configuration file error expected")
+			return
+		}
+	})
+
+	// Test world readable configuration file with encrypted password
+	t.Run("Test world readable configuration file with encrypted password", func(t *testing.T) {
+		encodedKey, _ := GenerateKey()
+		key, _ := ParseKey(encodedKey)
+		encrypted, _ := Encrypt("postgres", key)
+		t.Setenv("DBCONFIG_KEY", encodedKey)
+		writeConfig(t, encrypted, 0644)
+
+		_, err := LoadConfigWithOptions(dirName, LoadOptions{PermissionCheck: PermissionCheckFail})
+		if err != nil {
+			t.Errorf("Error loading configuration: %s", err.Error())
+			return
+		}
+	})
+
+	// Test group readable key file in warn mode
+	t.Run("Test group readable key file in warn mode", func(t *testing.T) {
+		writeConfig(t, "postgres", 0600)
+		writeFile(t, keyFile, "key", 0640)
+		defer writeFile(t, keyFile, "key", 0600)
+
+		var warnings []error
+		options := LoadOptions{
+			PermissionCheck: PermissionCheckWarn,
+			PermissionWarning: func(violation error) {
+				warnings = append(warnings, violation)
+			},
+		}
+		_, err := LoadConfigWithOptions(dirName, options)
+		if err != nil {
+			t.Errorf("The warn mode must not fail: %s", err.Error())
+			return
+		}
+		// a root owned key may be readable by the group
+		expected := 1
+		if os.Getuid() == 0 {
+			expected = 0
+		}
+		if len(warnings) != expected {
+			t.Errorf("%d warnings expected, got %d", expected, len(warnings))
+			return
+		}
+		if expected == 1 && !errorex.IS(warnings[0], ErrorCodeInsecureKeyFile) {
+			t.Errorf("Insecure key file warning expected")
+			return
+		}
+	})
+
+	// Test world readable key file
+	t.Run("Test world readable key file", func(t *testing.T) {
+		writeConfig(t, "postgres", 0600)
+		writeFile(t, keyFile, "key", 0644)
+		defer writeFile(t, keyFile, "key", 0600)
+
+		_, err := LoadConfigWithOptions(dirName, LoadOptions{PermissionCheck: PermissionCheckFail})
+		if !errorex.IS(err, ErrorCodeInsecureKeyFile) {
+			t.Errorf("Insecure key file error expected")
+			return
+		}
+
+		// the off mode disables the checks
+		_, err = LoadConfigWithOptions(dirName, LoadOptions{PermissionCheck: PermissionCheckOff})
+		if err != nil {
+			t.Errorf("Error loading configuration: %s", err.Error())
+			return
+		}
+	})
+
+	// Test world writable certificate file
+	t.Run("Test world writable certificate file", func(t *testing.T) {
+		writeConfig(t, "postgres", 0600)
+		writeFile(t, dirName+"/ca.crt", "ca", 0666)
+
+		violations := CheckPermissions(configFile, Config{SSL: &SSLConfig{Ca: dirName + "/ca.crt"}})
+		if len(violations) != 1 || !errorex.IS(violations[0], ErrorCodeInsecureCertFile) {
+			t.Errorf("Insecure certificate file error expected")
+			return
+		}
+	})
+
+	// Test permission checks are off by default
+	t.Run("Test permission checks are off by default", func(t *testing.T) {
+		writeConfig(t, "postgres", 0644)
+		writeFile(t, keyFile, "key", 0644)
+		defer writeFile(t, keyFile, "key", 0600)
+
+		var warnings []error
+		options := LoadOptions{
+			PermissionWarning: func(violation error) {
+				warnings = append(warnings, violation)
+			},
+		}
+		if _, err := LoadConfigWithOptions(dirName, options); err != nil {
+			t.Errorf("Error loading configuration: %s", err.Error())
+			return
+		}
+		if len(warnings) != 0 {
+			t.Errorf("No warnings expected without a permission check mode, got %v", warnings)
+			return
+		}
+	})
+
+	// Test world readable password file
+	t.Run("Test world readable password file", func(t *testing.T) {
+		writeFile(t, dirName+"/pgpass", "*:*:*:*:secret\n", 0644)
+		defer func() {
+			_ = os.Remove(dirName + "/pgpass")
+		}()
+
+		violations := CheckPermissions("", Config{Type: DbTypePostgres})
+		if len(violations) != 1 || !errorex.IS(violations[0], ErrorCodeInsecurePgpassFile) {
+			t.Errorf("Insecure password file error expected")
+			return
+		}
+		// only the PostgreSQL family reads the password file
+		if violations := CheckPermissions("", Config{Type: DbTypeMysql}); len(violations) != 0 {
+			t.Errorf("No violations expected for MYSQL, got %v", violations)
+			return
+		}
+	})
+
+	// Test file owned by another user
+	t.Run("Test file owned by another user", func(t *testing.T) {
+		if os.Getuid() != 0 {
+			t.Skip("Changing the file owner requires root")
+		}
+		writeFile(t, keyFile, "key", 0600)
+		if err := os.Chown(keyFile, 4242, 4242); err != nil {
+			t.Errorf("Error changing file owner: %s", err.Error())
+			return
+		}
+		defer func() {
+			_ = os.Chown(keyFile, 0, 0)
+		}()
+
+		violations := CheckPermissions("", Config{SSL: &SSLConfig{Key: keyFile}})
+		if len(violations) != 1 || !errorex.IS(violations[0], ErrorCodeInsecureFileOwner) {
+			t.Errorf("Insecure file owner error expected")
+			return
+		}
+	})
+
+}
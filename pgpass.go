@@ -0,0 +1,221 @@
+/*
+ * © 2023 fkmatsuda
+
+ * This file is licensed under the terms of the MIT license. Permission is hereby
+ * granted, free of charge, to any person obtaining a copy of this software and
+ * associated documentation files (the "Software"), to deal in the Software without
+ * restriction, including without limitation the rights to use, copy, modify,
+ * merge, publish, and/or distribute copies of the Software, and to permit persons
+ * to whom the Software is furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS," WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY,
+ * WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+ * CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ *
+ */
+
+package dbconfig
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"github.com/fkmatsuda-dev/commons/errorex"
+)
+
+const (
+	insecurePgpassFile = "Insecure password file permissions"
+	pgpassWriteError   = "Password file write error"
+)
+
+// usesPgpass checks if the passwords of the DbType can be read from the libpq password file
+func (s DbType) usesPgpass() bool {
+	return s.Family() == DbTypePostgres
+}
+
+// PgpassFile returns the libpq password file, the PGPASSFILE environment variable or ~/.pgpass
+// (%APPDATA%\postgresql\pgpass.conf on windows)
+func PgpassFile() string {
+	if file, chk := lookupEnv("PGPASSFILE"); chk {
+		return file
+	}
+	if runtime.GOOS == "windows" {
+		return filepath.Join(os.Getenv("APPDATA"), "postgresql", "pgpass.conf")
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".pgpass")
+}
+
+// LookupPgpass looks up the password of the connection in the libpq password file, following the libpq rules:
+// the first line whose hostname, port, database and username fields match wins, * matches any value, \: and \\
+// escape the colons and backslashes, and the file is ignored when it is accessible by the group or by others
+func LookupPgpass(host string, port uint16, database string, user string) (string, bool) {
+	return lookupPgpassFile(PgpassFile(), host, port, database, user)
+}
+
+func lookupPgpassFile(pgpassFile string, host string, port uint16, database string, user string) (string, bool) {
+	if pgpassFile == "" {
+		return "", false
+	}
+	info, err := os.Stat(pgpassFile)
+	if err != nil || !info.Mode().IsRegular() {
+		return "", false
+	}
+	// libpq warns about the insecure files, the library does not write to the standard logger and only ignores them
+	if runtime.GOOS != "windows" && info.Mode().Perm()&0077 != 0 {
+		return "", false
+	}
+
+	file, err := os.Open(pgpassFile)
+	if err != nil {
+		return "", false
+	}
+	defer func(file *os.File) {
+		_ = file.Close()
+	}(file)
+
+	// libpq matches the unix socket connections against localhost
+	if host == "" || strings.HasPrefix(host, "/") {
+		host = "localhost"
+	}
+	values := []string{host, strconv.Itoa(int(port)), database, user}
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := splitPgpassLine(line)
+		if len(fields) < 5 {
+			continue
+		}
+		matched := true
+		for i, value := range values {
+			if fields[i] != "*" && fields[i] != value {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return fields[4], true
+		}
+	}
+	return "", false
+}
+
+// splitPgpassLine splits a password file line in its fields, the escapes are removed and the password field keeps
+// the colons of the rest of the line
+func splitPgpassLine(line string) []string {
+	var fields []string
+	var field strings.Builder
+	for i := 0; i < len(line); i++ {
+		switch {
+		case line[i] == '\\' && i+1 < len(line):
+			i++
+			field.WriteByte(line[i])
+		case line[i] == ':' && len(fields) < 4:
+			fields = append(fields, field.String())
+			field.Reset()
+		default:
+			field.WriteByte(line[i])
+		}
+	}
+	return append(fields, field.String())
+}
+
+// PgpassEntry returns the libpq password file line of the configuration, the unix socket connections are written as
+// localhost like libpq matches them
+func (c Config) PgpassEntry() string {
+	host := c.Host
+	if host == "" || isSocketDir(host) {
+		host = "localhost"
+	}
+	fields := []string{host, strconv.Itoa(int(c.Port)), c.Database, c.User, c.Password}
+	for i, field := range fields {
+		fields[i] = strings.NewReplacer(`\`, `\\`, `:`, `\:`).Replace(field)
+	}
+	return strings.Join(fields, ":")
+}
+
+// WritePgpass writes the libpq password file line of the configuration to the file with the 0600 mode, the other
+// lines of an existing file are kept and the line of the same hostname, port, database and username is replaced
+func WritePgpass(file string, config Config) error {
+	entry := config.PgpassEntry()
+	content, err := os.ReadFile(file)
+	if err != nil && !os.IsNotExist(err) {
+		return errorex.New(ErrorCodePgpassWriteError, pgpassWriteError, err.Error())
+	}
+	var lines []string
+	if len(content) > 0 {
+		lines = strings.Split(strings.TrimSuffix(string(content), "\n"), "\n")
+	}
+	replaced := false
+	for i, line := range lines {
+		if !replaced && !strings.HasPrefix(line, "#") && samePgpassConnection(line, entry) {
+			lines[i] = entry
+			replaced = true
+		}
+	}
+	if !replaced {
+		lines = append(lines, entry)
+	}
+	if err := writeSecretFile(file, []byte(strings.Join(lines, "\n")+"\n")); err != nil {
+		return errorex.New(ErrorCodePgpassWriteError, pgpassWriteError, err.Error())
+	}
+	return nil
+}
+
+// samePgpassConnection checks if the password file lines have the same hostname, port, database and username fields
+func samePgpassConnection(line string, entry string) bool {
+	fields, entryFields := splitPgpassLine(line), splitPgpassLine(entry)
+	if len(fields) < 5 {
+		return false
+	}
+	for i := 0; i < 4; i++ {
+		if fields[i] != entryFields[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// WriteTempPgpass writes the libpq password file line of the configuration to a new temporary file with the 0600
+// mode and returns its name, the caller must remove the file, psql and pg_dump read it from PGPASSFILE
+func WriteTempPgpass(config Config) (string, error) {
+	file, err := os.CreateTemp("", "dbconfig-*.pgpass")
+	if err != nil {
+		return "", errorex.New(ErrorCodePgpassWriteError, pgpassWriteError, err.Error())
+	}
+	_ = file.Close()
+	if err := WritePgpass(file.Name(), config); err != nil {
+		_ = os.Remove(file.Name())
+		return "", err
+	}
+	return file.Name(), nil
+}
+
+// resolvePgpassPassword sets the password of the PostgreSQL family configurations without password from the
+// libpq password file
+func resolvePgpassPassword(config Config) Config {
+	if config.Password != "" || !config.Type.usesPgpass() {
+		return config
+	}
+	if password, ok := LookupPgpass(config.Host, config.Port, config.Database, config.User); ok {
+		config.Password = password
+	}
+	return config
+}
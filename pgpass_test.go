@@ -0,0 +1,229 @@
+/*
+ * © 2023 fkmatsuda
+
+ * This file is licensed under the terms of the MIT license. Permission is hereby
+ * granted, free of charge, to any person obtaining a copy of this software and
+ * associated documentation files (the "Software"), to deal in the Software without
+ * restriction, including without limitation the rights to use, copy, modify,
+ * merge, publish, and/or distribute copies of the Software, and to permit persons
+ * to whom the Software is furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS," WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY,
+ * WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+ * CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ *
+ */
+
+package dbconfig
+
+import (
+	"os"
+	"runtime"
+	"testing"
+
+	"github.com/fkmatsuda-dev/commons/files"
+)
+
+func TestPgpass(t *testing.T) {
+
+	// Create a temporary directory inside system temp directory
+	dirName, err := files.CreateTempDir()
+	if err != nil {
+		t.Errorf("Error creating temporary directory: %s", err.Error())
+		return
+	}
+	defer func() {
+		_ = files.CleanupTempDirs()
+	}()
+
+	pgpassFile := dirName + "/pgpass"
+	content := `# hostname:port:database:username:password
+db.example.com:5432:orders:app:orders-password
+db.example.com:*:*:app:app\:pass\\word
+*:*:*:reports:reports:password
+localhost:5432:*:*:local-password
+`
+	if err := os.WriteFile(pgpassFile, []byte(content), 0600); err != nil {
+		t.Errorf("Error writing password file: %s", err.Error())
+		return
+	}
+
+	// Test lookup password
+	t.Run("Test lookup password", func(t *testing.T) {
+		t.Setenv("PGPASSFILE", pgpassFile)
+
+		lookups := []struct {
+			host     string
+			port     uint16
+			database string
+			user     string
+			password string
+		}{
+			{"db.example.com", 5432, "orders", "app", "orders-password"},
+			{"db.example.com", 5433, "billing", "app", `app:pass\word`},
+			{"other.example.com", 26257, "reports", "reports", "reports:password"},
+			{"", 5432, "postgres", "postgres", "local-password"},
+			{"/var/run/postgresql", 5432, "postgres", "postgres", "local-password"},
+		}
+		for _, lookup := range lookups {
+			password, ok := LookupPgpass(lookup.host, lookup.port, lookup.database, lookup.user)
+			if !ok || password != lookup.password {
+				t.Errorf("The password \"%s\" expected for %v, got \"%s\"", lookup.password, lookup, password)
+			}
+		}
+
+		if _, ok := LookupPgpass("other.example.com", 5432, "orders", "app"); ok {
+			t.Errorf("No password expected")
+			return
+		}
+	})
+
+	// Test password file with group access is ignored
+	t.Run("Test password file with group access is ignored", func(t *testing.T) {
+		if runtime.GOOS == "windows" {
+			t.Skip("The file modes are not checked on windows")
+		}
+		insecureFile := dirName + "/insecure-pgpass"
+		if err := os.WriteFile(insecureFile, []byte(content), 0640); err != nil {
+			t.Errorf("Error writing password file: %s", err.Error())
+			return
+		}
+		_ = os.Chmod(insecureFile, 0640)
+		t.Setenv("PGPASSFILE", insecureFile)
+
+		if _, ok := LookupPgpass("db.example.com", 5432, "orders", "app"); ok {
+			t.Errorf("The insecure password file must be ignored")
+			return
+		}
+	})
+
+	// Test load by environment variables with password file
+	t.Run("Test load by environment variables with password file", func(t *testing.T) {
+		t.Setenv("PGPASSFILE", pgpassFile)
+		t.Setenv("DB_TYPE", "POSTGRESQL")
+		t.Setenv("DB_HOST", "db.example.com")
+		t.Setenv("DB_USER", "app")
+		t.Setenv("DB_DATABASE", "orders")
+
+		config, err := LoadFromEnv()
+		if err != nil {
+			t.Errorf("Error loading configuration: %s", err.Error())
+			return
+		}
+		if config.Password != "orders-password" {
+			t.Errorf("The password must be read from the password file")
+			return
+		}
+
+		// MySQL passwords are not read from the password file
+		t.Setenv("DB_TYPE", "MYSQL")
+		if _, err := LoadFromEnv(); err == nil {
+			t.Errorf("Error expected")
+			return
+		}
+	})
+
+	// Test write password file
+	t.Run("Test write password file", func(t *testing.T) {
+		config := Config{
+			Type:     DbTypePostgres,
+			Host:     "db.example.com",
+			Port:     5432,
+			User:     "app",
+			Password: `s3:cr\t`,
+			Database: "orders",
+		}
+		if entry := config.PgpassEntry(); entry != `db.example.com:5432:orders:app:s3\:cr\\t` {
+			t.Errorf("Unexpected password file entry: %s", entry)
+			return
+		}
+		socketConfig := config
+		socketConfig.Host = "/var/run/postgresql"
+		if entry := socketConfig.PgpassEntry(); entry != `localhost:5432:orders:app:s3\:cr\\t` {
+			t.Errorf("Unexpected password file entry: %s", entry)
+			return
+		}
+
+		file, err := WriteTempPgpass(config)
+		if err != nil {
+			t.Errorf("Error writing password file: %s", err.Error())
+			return
+		}
+		defer func() {
+			_ = os.Remove(file)
+		}()
+
+		info, err := os.Stat(file)
+		if err != nil {
+			t.Errorf("Error reading password file: %s", err.Error())
+			return
+		}
+		if runtime.GOOS != "windows" && info.Mode().Perm() != 0600 {
+			t.Errorf("The password file mode must be 0600, got %04o", info.Mode().Perm())
+			return
+		}
+
+		// the written entry must be read back by the lookup
+		password, ok := lookupPgpassFile(file, config.Host, config.Port, config.Database, config.User)
+		if !ok || password != config.Password {
+			t.Errorf("The written password must be read back")
+			return
+		}
+	})
+
+	// Test write existing password file
+	t.Run("Test write existing password file", func(t *testing.T) {
+		file := dirName + "/existing-pgpass"
+		existing := "# team databases\nreports.example.com:5432:*:app:reports\ndb.example.com:5432:orders:app:old\n"
+		if err := os.WriteFile(file, []byte(existing), 0644); err != nil {
+			t.Errorf("Error writing password file: %s", err.Error())
+			return
+		}
+		config := Config{
+			Type:     DbTypePostgres,
+			Host:     "db.example.com",
+			Port:     5432,
+			User:     "app",
+			Password: "new",
+			Database: "orders",
+		}
+		if err := WritePgpass(file, config); err != nil {
+			t.Errorf("Error writing password file: %s", err.Error())
+			return
+		}
+		// the other database is appended
+		config.Database = "billing"
+		if err := WritePgpass(file, config); err != nil {
+			t.Errorf("Error writing password file: %s", err.Error())
+			return
+		}
+
+		content, err := os.ReadFile(file)
+		if err != nil {
+			t.Errorf("Error reading password file: %s", err.Error())
+			return
+		}
+		expected := "# team databases\nreports.example.com:5432:*:app:reports\ndb.example.com:5432:orders:app:new\n" +
+			"db.example.com:5432:billing:app:new\n"
+		if string(content) != expected {
+			t.Errorf("The password file %q expected, got %q", expected, string(content))
+			return
+		}
+		info, err := os.Stat(file)
+		if err != nil {
+			t.Errorf("Error reading password file: %s", err.Error())
+			return
+		}
+		if runtime.GOOS != "windows" && info.Mode().Perm() != 0600 {
+			t.Errorf("The password file mode must be 0600, got %04o", info.Mode().Perm())
+			return
+		}
+	})
+
+}
@@ -0,0 +1,232 @@
+/*
+ * © 2023 fkmatsuda
+
+ * This file is licensed under the terms of the MIT license. Permission is hereby
+ * granted, free of charge, to any person obtaining a copy of this software and
+ * associated documentation files (the "Software"), to deal in the Software without
+ * restriction, including without limitation the rights to use, copy, modify,
+ * merge, publish, and/or distribute copies of the Software, and to permit persons
+ * to whom the Software is furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS," WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY,
+ * WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+ * CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ *
+ */
+
+package dbconfig
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/fkmatsuda-dev/commons/errorex"
+)
+
+const secretParseError = "Secret payload parse error"
+
+// secretKeys maps each configuration field to the payload keys used for it by the
+// AWS Secrets Manager, GCP Secret Manager and Azure Key Vault conventions, keys are
+// compared in lower case
+var secretKeys = []struct {
+	field string
+	keys  []string
+}{
+	{"type", []string{"engine", "type", "db_type", "dbtype", "database_version"}},
+	{"host", []string{"host", "hostname", "instance_host", "db_host", "endpoint"}},
+	{"port", []string{"port", "db_port"}},
+	{"user", []string{"username", "user", "db_user", "login"}},
+	{"password", []string{"password", "db_pass", "db_password"}},
+	{"database", []string{"dbname", "database", "db_name", "db_database"}},
+	{"sslmode", []string{"sslmode", "ssl_mode", "ssl"}},
+}
+
+// secretKeyPrefixes are the key prefixes used by Azure Service Connector, the prefix
+// also identifies the database engine
+var secretKeyPrefixes = map[string]DbType{
+	"azure_postgresql_": DbTypePostgres,
+	"azure_mysql_":      DbTypeMysql,
+}
+
+// SecretEngineValue maps the engine names used by the cloud providers to a DbType
+var SecretEngineValue = map[string]DbType{
+	"mysql":             DbTypeMysql,
+	"aurora":            DbTypeMysql,
+	"aurora-mysql":      DbTypeMysql,
+	"mariadb":           DbTypeMariadb,
+	"tidb":              DbTypeTidb,
+	"postgres":          DbTypePostgres,
+	"postgresql":        DbTypePostgres,
+	"aurora-postgresql": DbTypePostgres,
+	"cockroach":         DbTypeCockroachdb,
+	"cockroachdb":       DbTypeCockroachdb,
+	"sqlserver":         DbTypeSqlserver,
+	"sqlserver-ee":      DbTypeSqlserver,
+	"sqlserver-se":      DbTypeSqlserver,
+	"sqlserver-ex":      DbTypeSqlserver,
+	"sqlserver-web":     DbTypeSqlserver,
+	"oracle":            DbTypeOracle,
+	"oracle-ee":         DbTypeOracle,
+	"oracle-ee-cdb":     DbTypeOracle,
+	"oracle-se2":        DbTypeOracle,
+	"oracle-se2-cdb":    DbTypeOracle,
+	"mongo":             DbTypeMongodb,
+	"mongodb":           DbTypeMongodb,
+	"docdb":             DbTypeMongodb,
+	"redis":             DbTypeRedis,
+	"clickhouse":        DbTypeClickhouse,
+}
+
+// ParseSecretEngine parses the engine name of a secret payload to a DbType
+// engine names are case-insensitive and the GCP database versions like POSTGRES_15 or MYSQL_8_0 are accepted
+func ParseSecretEngine(engine string) (DbType, error) {
+	value := strings.ToLower(strings.TrimSpace(engine))
+	if dbType, ok := SecretEngineValue[value]; ok {
+		return dbType, nil
+	}
+	// GCP Cloud SQL database versions are the engine name followed by the version
+	if idx := strings.IndexByte(value, '_'); idx > 0 {
+		if dbType, ok := SecretEngineValue[value[:idx]]; ok {
+			return dbType, nil
+		}
+	}
+	return ParseDbType(engine)
+}
+
+// ParseSecretJSON parses a cloud secret manager JSON payload to a struct Config
+// the keys of the AWS Secrets Manager RDS secrets (engine, host, port, username, password and dbname) are
+// supported along with the GCP Cloud SQL and Azure Service Connector conventions,
+// fields missing from the payload are left empty and the payloads with two keys for the same value, like host and
+// AZURE_POSTGRESQL_HOST, are rejected
+func ParseSecretJSON(data []byte) (Config, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var payload map[string]interface{}
+	if err := decoder.Decode(&payload); err != nil {
+		return Config{}, errorex.New(ErrorCodeSecretParseError, secretParseError, err.Error())
+	}
+
+	// normalize the payload keys, the keys that normalize to the same key and the mixed prefixes are ambiguous
+	var prefixType DbType
+	values := make(map[string]string, len(payload))
+	sources := make(map[string]string, len(payload))
+	for payloadKey, value := range payload {
+		key := strings.ToLower(payloadKey)
+		for prefix, dbType := range secretKeyPrefixes {
+			if strings.HasPrefix(key, prefix) {
+				if prefixType != 0 && prefixType != dbType {
+					return Config{}, errorex.New(
+						ErrorCodeSecretParseError,
+						secretParseError,
+						"the payload mixes the keys of different Azure Service Connector engines",
+					)
+				}
+				key = strings.TrimPrefix(key, prefix)
+				prefixType = dbType
+				break
+			}
+		}
+		if source, ok := sources[key]; ok {
+			// sort the keys so the error does not depend on the map order
+			keys := []string{source, payloadKey}
+			sort.Strings(keys)
+			return Config{}, errorex.New(
+				ErrorCodeSecretParseError,
+				secretParseError,
+				fmt.Sprintf("\"%s\" and \"%s\" keys set the same value", keys[0], keys[1]),
+			)
+		}
+		sources[key] = payloadKey
+		values[key] = secretValue(value)
+	}
+
+	// pick the first key set for each field
+	fields := make(map[string]string, len(secretKeys))
+	for _, secretKey := range secretKeys {
+		for _, key := range secretKey.keys {
+			if value, ok := values[key]; ok && value != "" {
+				fields[secretKey.field] = value
+				break
+			}
+		}
+	}
+
+	config := Config{
+		Type:     prefixType,
+		Host:     fields["host"],
+		User:     fields["user"],
+		Password: fields["password"],
+		Database: fields["database"],
+	}
+
+	if engine, ok := fields["type"]; ok {
+		dbType, err := ParseSecretEngine(engine)
+		if err != nil {
+			return Config{}, err
+		}
+		config.Type = dbType
+	}
+
+	if strPort, ok := fields["port"]; ok {
+		port, err := strconv.ParseUint(strPort, 10, 16)
+		if err != nil || port == 0 {
+			return Config{}, errorex.New(
+				ErrorCodeSecretParseError,
+				secretParseError,
+				fmt.Sprintf("\"%s\" value for port is invalid", strPort),
+			)
+		}
+		config.Port = uint16(port)
+	} else {
+		config.Port = config.Type.DefaultPort()
+	}
+
+	if strSSLMode, ok := fields["sslmode"]; ok {
+		sslMode, err := parseSecretSSLMode(strSSLMode)
+		if err != nil {
+			return Config{}, err
+		}
+		config.SSL = &SSLConfig{Mode: sslMode}
+	}
+
+	return config, nil
+}
+
+// parseSecretSSLMode parses the ssl value of a secret payload, the boolean flags used by Azure are mapped to
+// the disable and require modes
+func parseSecretSSLMode(value string) (SSLMode, error) {
+	switch strings.ToLower(value) {
+	case "true":
+		return SSLModeRequire, nil
+	case "false":
+		return SSLModeDisable, nil
+	}
+	return ParseSSLMode(value)
+}
+
+// secretValue formats a decoded JSON value as a string
+func secretValue(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case json.Number:
+		return v.String()
+	case bool:
+		return strconv.FormatBool(v)
+	default:
+		encoded, _ := json.Marshal(v)
+		return string(encoded)
+	}
+}
@@ -0,0 +1,178 @@
+/*
+ * © 2023 fkmatsuda
+
+ * This file is licensed under the terms of the MIT license. Permission is hereby
+ * granted, free of charge, to any person obtaining a copy of this software and
+ * associated documentation files (the "Software"), to deal in the Software without
+ * restriction, including without limitation the rights to use, copy, modify,
+ * merge, publish, and/or distribute copies of the Software, and to permit persons
+ * to whom the Software is furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS," WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY,
+ * WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+ * CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ *
+ */
+
+package dbconfig
+
+import (
+	"testing"
+
+	"github.com/fkmatsuda-dev/commons/errorex"
+)
+
+func TestParseSecretJSON(t *testing.T) {
+
+	// Test AWS Secrets Manager RDS secret
+	t.Run("Test AWS Secrets Manager RDS secret", func(t *testing.T) {
+		secret := `{
+			"engine": "aurora-postgresql",
+			"host": "db.cluster-abc.us-east-1.rds.amazonaws.com",
+			"port": 5432,
+			"username": "app",
+			"password": "s3cr3t",
+			"dbname": "orders",
+			"dbClusterIdentifier": "db"
+		}`
+
+		expected := Config{
+			Type:     DbTypePostgres,
+			Host:     "db.cluster-abc.us-east-1.rds.amazonaws.com",
+			Port:     5432,
+			User:     "app",
+			Password: "s3cr3t",
+			Database: "orders",
+		}
+
+		config, err := ParseSecretJSON([]byte(secret))
+		if err != nil {
+			t.Errorf("Error parsing secret: %s", err.Error())
+			return
+		}
+		if !expected.compare(config) {
+			t.Errorf("The parsed configuration is different from the expected configuration")
+			return
+		}
+	})
+
+	// Test GCP Cloud SQL secret with database version and string port
+	t.Run("Test GCP Cloud SQL secret with database version and string port", func(t *testing.T) {
+		secret := `{
+			"database_version": "MYSQL_8_0",
+			"INSTANCE_HOST": "10.0.0.3",
+			"DB_PORT": "3307",
+			"DB_USER": "app",
+			"DB_PASS": "s3cr3t",
+			"DB_NAME": "orders"
+		}`
+
+		expected := Config{
+			Type:     DbTypeMysql,
+			Host:     "10.0.0.3",
+			Port:     3307,
+			User:     "app",
+			Password: "s3cr3t",
+			Database: "orders",
+		}
+
+		config, err := ParseSecretJSON([]byte(secret))
+		if err != nil {
+			t.Errorf("Error parsing secret: %s", err.Error())
+			return
+		}
+		if !expected.compare(config) {
+			t.Errorf("The parsed configuration is different from the expected configuration")
+			return
+		}
+	})
+
+	// Test Azure Service Connector secret
+	t.Run("Test Azure Service Connector secret", func(t *testing.T) {
+		secret := `{
+			"AZURE_POSTGRESQL_HOST": "app.postgres.database.azure.com",
+			"AZURE_POSTGRESQL_USER": "app",
+			"AZURE_POSTGRESQL_PASSWORD": "s3cr3t",
+			"AZURE_POSTGRESQL_DATABASE": "orders",
+			"AZURE_POSTGRESQL_SSL": "true"
+		}`
+
+		expected := Config{
+			Type:     DbTypePostgres,
+			Host:     "app.postgres.database.azure.com",
+			Port:     5432,
+			User:     "app",
+			Password: "s3cr3t",
+			Database: "orders",
+			SSL: &SSLConfig{
+				Mode: SSLModeRequire,
+			},
+		}
+
+		config, err := ParseSecretJSON([]byte(secret))
+		if err != nil {
+			t.Errorf("Error parsing secret: %s", err.Error())
+			return
+		}
+		if !expected.compare(config) {
+			t.Errorf("The parsed configuration is different from the expected configuration")
+			return
+		}
+	})
+
+	// Test secret with unknown engine
+	t.Run("Test secret with unknown engine", func(t *testing.T) {
+		_, err := ParseSecretJSON([]byte(`{"engine": "db2", "host": "localhost"}`))
+		if !errorex.IS(err, ErrorCodeDbTypeParseError) {
+			t.Errorf("DbType parse error expected")
+			return
+		}
+	})
+
+	// Test secret with invalid port
+	t.Run("Test secret with invalid port", func(t *testing.T) {
+		for _, port := range []string{`"invalid"`, `"0"`, `0`} {
+			_, err := ParseSecretJSON([]byte(`{"engine": "postgres", "port": ` + port + `}`))
+			if !errorex.IS(err, ErrorCodeSecretParseError) {
+				t.Errorf("Secret parse error expected for the %s port", port)
+				return
+			}
+		}
+	})
+
+	// Test secret with colliding keys
+	t.Run("Test secret with colliding keys", func(t *testing.T) {
+		payloads := []string{
+			`{"AZURE_POSTGRESQL_HOST": "azure.example.com", "host": "aws.example.com"}`,
+			`{"host": "aws.example.com", "AZURE_POSTGRESQL_HOST": "azure.example.com"}`,
+			`{"AZURE_POSTGRESQL_HOST": "pg.example.com", "AZURE_MYSQL_USER": "app"}`,
+			`{"AZURE_MYSQL_USER": "app", "AZURE_POSTGRESQL_HOST": "pg.example.com"}`,
+			`{"Host": "a.example.com", "HOST": "b.example.com"}`,
+		}
+		for _, payload := range payloads {
+			// the map order changes between the runs, the payload must be rejected on each of them
+			for i := 0; i < 10; i++ {
+				if _, err := ParseSecretJSON([]byte(payload)); !errorex.IS(err, ErrorCodeSecretParseError) {
+					t.Errorf("Secret parse error expected for %s", payload)
+					return
+				}
+			}
+		}
+	})
+
+	// Test invalid secret payload
+	t.Run("Test invalid secret payload", func(t *testing.T) {
+		_, err := ParseSecretJSON([]byte(`not json`))
+		if !errorex.IS(err, ErrorCodeSecretParseError) {
+			t.Errorf("Secret parse error expected")
+			return
+		}
+	})
+
+}
@@ -0,0 +1,228 @@
+/*
+ * © 2023 fkmatsuda
+
+ * This file is licensed under the terms of the MIT license. Permission is hereby
+ * granted, free of charge, to any person obtaining a copy of this software and
+ * associated documentation files (the "Software"), to deal in the Software without
+ * restriction, including without limitation the rights to use, copy, modify,
+ * merge, publish, and/or distribute copies of the Software, and to permit persons
+ * to whom the Software is furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS," WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY,
+ * WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+ * CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ *
+ */
+
+package dbconfig
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/fkmatsuda-dev/commons/errorex"
+	"github.com/fkmatsuda-dev/commons/files"
+	"github.com/fkmatsuda-dev/env"
+)
+
+const (
+	serviceBindingNotFound   = "Service binding not found"
+	serviceBindingParseError = "Service binding parse error"
+)
+
+// LoadServiceBinding loads the database settings from the servicebinding.io binding with the given name
+// inside $SERVICE_BINDING_ROOT, when the name is empty the only database binding of the root is loaded
+func LoadServiceBinding(name string) (Config, error) {
+	if name != "" {
+		root, err := serviceBindingRoot()
+		if err != nil {
+			return Config{}, err
+		}
+		return LoadServiceBindingDir(filepath.Join(root, name))
+	}
+
+	bindings, err := LoadServiceBindings()
+	if err != nil {
+		return Config{}, err
+	}
+	if len(bindings) != 1 {
+		names := make([]string, 0, len(bindings))
+		for bindingName := range bindings {
+			names = append(names, bindingName)
+		}
+		sort.Strings(names)
+		return Config{}, errorex.New(
+			ErrorCodeServiceBindingNotFound,
+			serviceBindingNotFound,
+			fmt.Sprintf("expected a single database binding, found %d: %s", len(names), strings.Join(names, ", ")),
+		)
+	}
+	for _, config := range bindings {
+		return config, nil
+	}
+	return Config{}, nil
+}
+
+// LoadServiceBindings loads all database bindings inside $SERVICE_BINDING_ROOT and returns them by binding name
+// bindings whose type is not a database type are ignored
+func LoadServiceBindings() (map[string]Config, error) {
+	root, err := serviceBindingRoot()
+	if err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		return nil, errorex.New(ErrorCodeServiceBindingNotFound, serviceBindingNotFound, err.Error())
+	}
+
+	bindings := make(map[string]Config)
+	for _, entry := range entries {
+		// skip the hidden entries created by the kubernetes volume projection
+		if strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		dir := filepath.Join(root, entry.Name())
+		if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+			continue
+		}
+		bindingType, err := readBindingEntry(dir, "type")
+		if err != nil {
+			continue
+		}
+		if _, err := ParseSecretEngine(bindingType); err != nil {
+			continue
+		}
+		config, err := LoadServiceBindingDir(dir)
+		if err != nil {
+			return nil, err
+		}
+		bindings[entry.Name()] = config
+	}
+	return bindings, nil
+}
+
+// LoadServiceBindingDir loads the database settings from a servicebinding.io binding directory
+// the directory holds one file per key, the type, host, username, password and database keys are required
+// while port, sslmode, sslrootcert, sslcert and sslkey are optional. The username, password and database keys are
+// also optional for the MongoDB, Redis and ClickHouse bindings
+func LoadServiceBindingDir(dir string) (Config, error) {
+	if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+		return Config{}, errorex.New(
+			ErrorCodeServiceBindingNotFound,
+			serviceBindingNotFound,
+			fmt.Sprintf("%s is not a binding directory", dir),
+		)
+	}
+
+	values := make(map[string]string)
+	bindingType, _ := readBindingEntry(dir, "type")
+	secretEngine, _ := ParseSecretEngine(bindingType)
+	for _, key := range []string{"type", "host", "username", "password", "database"} {
+		value, err := readBindingEntry(dir, key)
+		optional := key != "type" && key != "host" && secretEngine.optionalCredentials()
+		if err != nil && !optional {
+			return Config{}, errorex.New(
+				ErrorCodeServiceBindingParseError,
+				serviceBindingParseError,
+				fmt.Sprintf("%s binding entry not found in %s", key, dir),
+			)
+		}
+		values[key] = value
+	}
+
+	dbType, err := ParseSecretEngine(values["type"])
+	if err != nil {
+		return Config{}, err
+	}
+
+	config := Config{
+		Type:     dbType,
+		Host:     values["host"],
+		Port:     dbType.DefaultPort(),
+		User:     values["username"],
+		Password: values["password"],
+		Database: values["database"],
+	}
+
+	if strPort, err := readBindingEntry(dir, "port"); err == nil {
+		port, err := strconv.ParseUint(strPort, 10, 16)
+		if err != nil {
+			return Config{}, errorex.New(
+				ErrorCodeServiceBindingParseError,
+				serviceBindingParseError,
+				fmt.Sprintf("\"%s\" value for port is invalid", strPort),
+			)
+		}
+		config.Port = uint16(port)
+	}
+
+	return loadBindingSSL(dir, config)
+}
+
+// loadBindingSSL loads the ssl settings of a binding directory, the certificate entries hold file names relative to
+// the binding directory, the ca.crt, tls.crt and tls.key entries of kubernetes tls secrets are used when present
+func loadBindingSSL(dir string, config Config) (Config, error) {
+	strSSLMode, err := readBindingEntry(dir, "sslmode")
+	if err != nil {
+		return config, nil
+	}
+	sslMode, err := ParseSSLMode(strSSLMode)
+	if err != nil {
+		return Config{}, err
+	}
+
+	sslConfig := SSLConfig{
+		Mode: sslMode,
+		Ca:   bindingFile(dir, "sslrootcert", "ca.crt"),
+		Cert: bindingFile(dir, "sslcert", "tls.crt"),
+		Key:  bindingFile(dir, "sslkey", "tls.key"),
+	}
+	config.SSL = &sslConfig
+	return config, nil
+}
+
+// bindingFile returns the path of the file named by the key entry or the path of the file entry when it exists
+func bindingFile(dir string, key string, file string) string {
+	if name, err := readBindingEntry(dir, key); err == nil {
+		if filepath.IsAbs(name) {
+			return name
+		}
+		return filepath.Join(dir, name)
+	}
+	if path := filepath.Join(dir, file); files.Exists(path) {
+		return path
+	}
+	return ""
+}
+
+// readBindingEntry reads the value of a binding entry without the trailing line break
+func readBindingEntry(dir string, key string) (string, error) {
+	value, err := files.ReadFile(filepath.Join(dir, key))
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(value, "\r\n"), nil
+}
+
+// serviceBindingRoot returns the bindings root directory from the SERVICE_BINDING_ROOT environment variable
+func serviceBindingRoot() (string, error) {
+	root, chk := env.ChkString("SERVICE_BINDING_ROOT")
+	if !chk {
+		return "", errorex.New(
+			ErrorCodeServiceBindingNotFound,
+			serviceBindingNotFound,
+			"SERVICE_BINDING_ROOT environment variable not found",
+		)
+	}
+	return root, nil
+}
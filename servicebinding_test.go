@@ -0,0 +1,184 @@
+/*
+ * © 2023 fkmatsuda
+
+ * This file is licensed under the terms of the MIT license. Permission is hereby
+ * granted, free of charge, to any person obtaining a copy of this software and
+ * associated documentation files (the "Software"), to deal in the Software without
+ * restriction, including without limitation the rights to use, copy, modify,
+ * merge, publish, and/or distribute copies of the Software, and to permit persons
+ * to whom the Software is furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS," WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY,
+ * WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+ * CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ *
+ */
+
+package dbconfig
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/fkmatsuda-dev/commons/errorex"
+	"github.com/fkmatsuda-dev/commons/files"
+)
+
+// writeBinding writes a binding directory with one file per entry
+func writeBinding(t *testing.T, root string, name string, entries map[string]string) string {
+	dir := filepath.Join(root, name)
+	if err := os.Mkdir(dir, 0755); err != nil {
+		t.Fatalf("Error creating binding directory: %s", err.Error())
+	}
+	for key, value := range entries {
+		if err := files.WriteFile(filepath.Join(dir, key), value); err != nil {
+			t.Fatalf("Error writing binding entry: %s", err.Error())
+		}
+	}
+	return dir
+}
+
+func TestLoadServiceBinding(t *testing.T) {
+
+	// Create a temporary directory inside system temp directory
+	root, err := files.CreateTempDir()
+	if err != nil {
+		t.Errorf("Error creating temporary directory: %s", err.Error())
+		return
+	}
+	defer func() {
+		_ = files.CleanupTempDirs()
+	}()
+
+	ordersDir := writeBinding(t, root, "orders-db", map[string]string{
+		"type":        "postgresql",
+		"provider":    "bitnami",
+		"host":        "orders.db.svc\n",
+		"port":        "5433",
+		"username":    "app",
+		"password":    "s3cr3t",
+		"database":    "orders",
+		"sslmode":     "verify-ca",
+		"sslrootcert": "root.crt",
+	})
+
+	// Test missing binding root
+	t.Run("Test missing binding root", func(t *testing.T) {
+		t.Setenv("SERVICE_BINDING_ROOT", "")
+		_, err := LoadServiceBinding("")
+		if !errorex.IS(err, ErrorCodeServiceBindingNotFound) {
+			t.Errorf("Service binding not found error expected")
+			return
+		}
+	})
+
+	// Test load binding by name
+	t.Run("Test load binding by name", func(t *testing.T) {
+		t.Setenv("SERVICE_BINDING_ROOT", root)
+
+		expected := Config{
+			Type:     DbTypePostgres,
+			Host:     "orders.db.svc",
+			Port:     5433,
+			User:     "app",
+			Password: "s3cr3t",
+			Database: "orders",
+			SSL: &SSLConfig{
+				Mode: SSLModeVerifyCA,
+				Ca:   filepath.Join(ordersDir, "root.crt"),
+			},
+		}
+
+		config, err := LoadServiceBinding("orders-db")
+		if err != nil {
+			t.Errorf("Error loading binding: %s", err.Error())
+			return
+		}
+		if !expected.compare(config) {
+			t.Errorf("The loaded configuration is different from the expected configuration")
+			return
+		}
+	})
+
+	// Test load the only database binding
+	t.Run("Test load the only database binding", func(t *testing.T) {
+		t.Setenv("SERVICE_BINDING_ROOT", root)
+
+		config, err := LoadServiceBinding("")
+		if err != nil {
+			t.Errorf("Error loading binding: %s", err.Error())
+			return
+		}
+		if config.Host != "orders.db.svc" {
+			t.Errorf("The orders-db binding expected")
+			return
+		}
+	})
+
+	// Test load multiple bindings
+	t.Run("Test load multiple bindings", func(t *testing.T) {
+		t.Setenv("SERVICE_BINDING_ROOT", root)
+
+		writeBinding(t, root, "cache", map[string]string{
+			"type": "rabbitmq",
+			"host": "queue.svc",
+		})
+		writeBinding(t, root, "legacy-db", map[string]string{
+			"type":     "mysql",
+			"host":     "legacy.db.svc",
+			"username": "legacy",
+			"password": "legacy",
+			"database": "legacy",
+			"ca.crt":   "-----BEGIN CERTIFICATE-----",
+			"sslmode":  "verify-ca",
+		})
+
+		bindings, err := LoadServiceBindings()
+		if err != nil {
+			t.Errorf("Error loading bindings: %s", err.Error())
+			return
+		}
+		if len(bindings) != 2 {
+			t.Errorf("Two database bindings expected, got %d", len(bindings))
+			return
+		}
+		legacy := bindings["legacy-db"]
+		if legacy.Type != DbTypeMysql || legacy.Port != 3306 {
+			t.Errorf("The legacy-db binding must be a MySQL binding on the default port")
+			return
+		}
+		if legacy.SSL == nil || legacy.SSL.Ca != filepath.Join(root, "legacy-db", "ca.crt") {
+			t.Errorf("The ca.crt binding entry must be used as the ssl root certificate")
+			return
+		}
+
+		// without a name the binding is ambiguous
+		_, err = LoadServiceBinding("")
+		if !errorex.IS(err, ErrorCodeServiceBindingNotFound) {
+			t.Errorf("Service binding not found error expected")
+			return
+		}
+	})
+
+	// Test binding without required entry
+	t.Run("Test binding without required entry", func(t *testing.T) {
+		dir := writeBinding(t, root, "incomplete", map[string]string{
+			"type": "postgresql",
+			"host": "localhost",
+		})
+
+		_, err := LoadServiceBindingDir(dir)
+		if !errorex.IS(err, ErrorCodeServiceBindingParseError) {
+			t.Errorf("Service binding parse error expected")
+			return
+		}
+	})
+
+}
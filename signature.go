@@ -0,0 +1,139 @@
+/*
+ * © 2023 fkmatsuda
+
+ * This file is licensed under the terms of the MIT license. Permission is hereby
+ * granted, free of charge, to any person obtaining a copy of this software and
+ * associated documentation files (the "Software"), to deal in the Software without
+ * restriction, including without limitation the rights to use, copy, modify,
+ * merge, publish, and/or distribute copies of the Software, and to permit persons
+ * to whom the Software is furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS," WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY,
+ * WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+ * CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ *
+ */
+
+package dbconfig
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/fkmatsuda-dev/commons/errorex"
+	"github.com/fkmatsuda-dev/commons/files"
+)
+
+const (
+	// signatureExtension is the extension of the detached signature files
+	signatureExtension = ".sig"
+
+	signatureNotFound     = "Configuration file signature not found"
+	signatureInvalid      = "Configuration file signature invalid"
+	trustedKeysParseError = "Trusted keys parse error"
+)
+
+// ParseTrustedKeys parses a list of base64 encoded ed25519 public keys separated by commas or white spaces
+func ParseTrustedKeys(value string) ([]ed25519.PublicKey, error) {
+	var keys []ed25519.PublicKey
+	fields := strings.FieldsFunc(value, func(r rune) bool {
+		return r == ',' || r == ' ' || r == '\t' || r == '\n' || r == '\r'
+	})
+	for _, field := range fields {
+		key, err := base64.StdEncoding.DecodeString(field)
+		if err != nil || len(key) != ed25519.PublicKeySize {
+			return nil, errorex.New(
+				ErrorCodeTrustedKeysParseError,
+				trustedKeysParseError,
+				fmt.Sprintf("\"%s\" is not a base64 encoded ed25519 public key", field),
+			)
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+// SignConfigFile signs the configuration file with the ed25519 private key and writes the base64 encoded detached
+// signature to the file with the .sig extension, with the 0600 mode of the configuration files
+func SignConfigFile(file string, privateKey ed25519.PrivateKey) error {
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return errorex.New(ErrorCodeConfigFileNotLoaded, "Configuration file not loaded", err.Error())
+	}
+	signature := ed25519.Sign(privateKey, content)
+	encoded := []byte(base64.StdEncoding.EncodeToString(signature) + "\n")
+	if err := writeSecretFile(file+signatureExtension, encoded); err != nil {
+		return errorex.New(ErrorCodeConfigFileWriteError, configFileWriteError, err.Error())
+	}
+	return nil
+}
+
+// VerifyConfigFile verifies the detached signature of the configuration file against the trusted keys
+func VerifyConfigFile(file string, trustedKeys []ed25519.PublicKey) error {
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return errorex.New(ErrorCodeConfigFileNotLoaded, "Configuration file not loaded", err.Error())
+	}
+	return verifySignature(file, content, trustedKeys)
+}
+
+// verifyConfigSignature verifies the signature of the configuration file content when the options or the
+// DBCONFIG_TRUSTED_KEYS environment variable require it
+func verifyConfigSignature(file string, content []byte, options LoadOptions) error {
+	trustedKeys, required, err := signatureRequirement(options)
+	if err != nil || !required {
+		return err
+	}
+	return verifySignature(file, content, trustedKeys)
+}
+
+// signatureRequirement returns the trusted keys of the options and the DBCONFIG_TRUSTED_KEYS environment variable and
+// whether a signed configuration file is required
+func signatureRequirement(options LoadOptions) ([]ed25519.PublicKey, bool, error) {
+	trustedKeys := options.TrustedKeys
+	if value, chk := lookupEnv("DBCONFIG_TRUSTED_KEYS"); chk {
+		envKeys, err := ParseTrustedKeys(value)
+		if err != nil {
+			return nil, false, err
+		}
+		trustedKeys = append(append([]ed25519.PublicKey{}, trustedKeys...), envKeys...)
+	}
+	return trustedKeys, options.RequireSignature || len(trustedKeys) > 0, nil
+}
+
+// verifySignature verifies the content against the detached signature of the file
+func verifySignature(file string, content []byte, trustedKeys []ed25519.PublicKey) error {
+	if len(trustedKeys) == 0 {
+		return errorex.New(ErrorCodeConfigSignatureInvalid, signatureInvalid, "no trusted keys to verify the signature")
+	}
+	encoded, err := files.ReadFile(file + signatureExtension)
+	if err != nil {
+		return errorex.New(ErrorCodeConfigSignatureNotFound, signatureNotFound, err.Error())
+	}
+	signature := []byte(encoded)
+	if len(signature) != ed25519.SignatureSize {
+		signature, err = base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+		if err != nil {
+			return errorex.New(ErrorCodeConfigSignatureInvalid, signatureInvalid, err.Error())
+		}
+	}
+	for _, key := range trustedKeys {
+		if len(key) == ed25519.PublicKeySize && ed25519.Verify(key, content, signature) {
+			return nil
+		}
+	}
+	return errorex.New(
+		ErrorCodeConfigSignatureInvalid,
+		signatureInvalid,
+		fmt.Sprintf("%s is not signed by a trusted key", file),
+	)
+}
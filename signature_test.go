@@ -0,0 +1,261 @@
+/*
+ * © 2023 fkmatsuda
+
+ * This file is licensed under the terms of the MIT license. Permission is hereby
+ * granted, free of charge, to any person obtaining a copy of this software and
+ * associated documentation files (the "Software"), to deal in the Software without
+ * restriction, including without limitation the rights to use, copy, modify,
+ * merge, publish, and/or distribute copies of the Software, and to permit persons
+ * to whom the Software is furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS," WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY,
+ * WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+ * CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ *
+ */
+
+package dbconfig
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"os"
+	"runtime"
+	"testing"
+
+	"github.com/fkmatsuda-dev/commons/errorex"
+	"github.com/fkmatsuda-dev/commons/files"
+)
+
+func TestConfigSignature(t *testing.T) {
+
+	// Create a temporary directory inside system temp directory
+	dirName, err := files.CreateTempDir()
+	if err != nil {
+		t.Errorf("Error creating temporary directory: %s", err.Error())
+		return
+	}
+	defer func() {
+		_ = files.CleanupTempDirs()
+	}()
+
+	publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Errorf("Error generating key: %s", err.Error())
+		return
+	}
+	otherPublicKey, otherPrivateKey, _ := ed25519.GenerateKey(rand.Reader)
+
+	configFile := dirName + "/dbconfig.json"
+	content := `{
+		"type": "POSTGRESQL",
+		"host": "localhost",
+		"port": 5432,
+		"user": "postgres",
+		"password": "postgres",
+		"database": "postgres"
+	}`
+	if err := files.WriteFile(configFile, content); err != nil {
+		t.Errorf("Error writing json configuration file: %s", err.Error())
+		return
+	}
+
+	// Test unsigned file is accepted without trusted keys
+	t.Run("Test unsigned file is accepted without trusted keys", func(t *testing.T) {
+		_, err := LoadConfig(dirName)
+		if err != nil {
+			t.Errorf("Error loading configuration: %s", err.Error())
+			return
+		}
+	})
+
+	// Test unsigned file is rejected
+	t.Run("Test unsigned file is rejected", func(t *testing.T) {
+		_, err := LoadConfigWithOptions(dirName, LoadOptions{TrustedKeys: []ed25519.PublicKey{publicKey}})
+		if !errorex.IS(err, ErrorCodeConfigSignatureNotFound) {
+			t.Errorf("Configuration file signature not found error expected")
+			return
+		}
+	})
+
+	if err := SignConfigFile(configFile, privateKey); err != nil {
+		t.Errorf("Error signing configuration file: %s", err.Error())
+		return
+	}
+
+	// Test signed file with trusted key option
+	t.Run("Test signed file with trusted key option", func(t *testing.T) {
+		options := LoadOptions{TrustedKeys: []ed25519.PublicKey{otherPublicKey, publicKey}}
+		config, err := LoadConfigWithOptions(dirName, options)
+		if err != nil {
+			t.Errorf("Error loading configuration: %s", err.Error())
+			return
+		}
+		if config.Host != "localhost" {
+			t.Errorf("The configuration file must be loaded")
+			return
+		}
+	})
+
+	// Test signed file with DBCONFIG_TRUSTED_KEYS
+	t.Run("Test signed file with DBCONFIG_TRUSTED_KEYS", func(t *testing.T) {
+		t.Setenv("DBCONFIG_TRUSTED_KEYS", base64.StdEncoding.EncodeToString(otherPublicKey)+","+
+			base64.StdEncoding.EncodeToString(publicKey))
+		_, err := LoadConfig(dirName)
+		if err != nil {
+			t.Errorf("Error loading configuration: %s", err.Error())
+			return
+		}
+	})
+
+	// Test signed file with untrusted key
+	t.Run("Test signed file with untrusted key", func(t *testing.T) {
+		t.Setenv("DBCONFIG_TRUSTED_KEYS", base64.StdEncoding.EncodeToString(otherPublicKey))
+		_, err := LoadConfig(dirName)
+		if !errorex.IS(err, ErrorCodeConfigSignatureInvalid) {
+			t.Errorf("Configuration file signature invalid error expected")
+			return
+		}
+	})
+
+	// Test tampered file
+	t.Run("Test tampered file", func(t *testing.T) {
+		if err := files.WriteFile(configFile, content+"\n"); err != nil {
+			t.Errorf("Error writing json configuration file: %s", err.Error())
+			return
+		}
+		err := VerifyConfigFile(configFile, []ed25519.PublicKey{publicKey})
+		if !errorex.IS(err, ErrorCodeConfigSignatureInvalid) {
+			t.Errorf("Configuration file signature invalid error expected")
+			return
+		}
+
+		// signing again with another key does not make the file trusted
+		if err := SignConfigFile(configFile, otherPrivateKey); err != nil {
+			t.Errorf("Error signing configuration file: %s", err.Error())
+			return
+		}
+		err = VerifyConfigFile(configFile, []ed25519.PublicKey{publicKey})
+		if !errorex.IS(err, ErrorCodeConfigSignatureInvalid) {
+			t.Errorf("Configuration file signature invalid error expected")
+			return
+		}
+	})
+
+	// Test required signature without trusted keys
+	t.Run("Test required signature without trusted keys", func(t *testing.T) {
+		_, err := LoadConfigWithOptions(dirName, LoadOptions{RequireSignature: true})
+		if !errorex.IS(err, ErrorCodeConfigSignatureInvalid) {
+			t.Errorf("Configuration file signature invalid error expected")
+			return
+		}
+	})
+
+	// Test required signature without configuration file
+	t.Run("Test required signature without configuration file", func(t *testing.T) {
+		emptyDir := dirName + "/empty"
+		if err := os.MkdirAll(emptyDir, 0700); err != nil {
+			t.Errorf("Error creating directory: %s", err.Error())
+			return
+		}
+		t.Setenv("CREDENTIALS_DIRECTORY", "")
+		t.Setenv("DB_TYPE", "POSTGRESQL")
+		t.Setenv("DB_HOST", "attacker.example.com")
+		t.Setenv("DB_PORT", "5432")
+		t.Setenv("DB_USER", "postgres")
+		t.Setenv("DB_PASSWORD", "postgres")
+		t.Setenv("DB_DATABASE", "postgres")
+
+		// the environment variables are not used instead of the signed file
+		_, err := LoadConfigWithOptions(emptyDir, LoadOptions{TrustedKeys: []ed25519.PublicKey{publicKey}})
+		if !errorex.IS(err, ErrorCodeConfigSignatureNotFound) {
+			t.Errorf("Configuration file signature not found error expected")
+			return
+		}
+		_, err = LoadConfigWithOptions(emptyDir, LoadOptions{RequireSignature: true})
+		if !errorex.IS(err, ErrorCodeConfigSignatureNotFound) {
+			t.Errorf("Configuration file signature not found error expected")
+			return
+		}
+
+		// the credential file must be signed
+		credentialsDir := dirName + "/credentials"
+		if err := os.MkdirAll(credentialsDir, 0700); err != nil {
+			t.Errorf("Error creating directory: %s", err.Error())
+			return
+		}
+		credentialFile := credentialsDir + "/dbconfig.json"
+		if err := files.WriteFile(credentialFile, content); err != nil {
+			t.Errorf("Error writing credential file: %s", err.Error())
+			return
+		}
+		t.Setenv("CREDENTIALS_DIRECTORY", credentialsDir)
+		options := LoadOptions{TrustedKeys: []ed25519.PublicKey{publicKey}}
+		if _, err := LoadConfigWithOptions(emptyDir, options); !errorex.IS(err, ErrorCodeConfigSignatureNotFound) {
+			t.Errorf("Configuration file signature not found error expected for the credential file")
+			return
+		}
+		if err := SignConfigFile(credentialFile, privateKey); err != nil {
+			t.Errorf("Error signing credential file: %s", err.Error())
+			return
+		}
+		config, err := LoadConfigWithOptions(emptyDir, options)
+		if err != nil {
+			t.Errorf("Error loading configuration: %s", err.Error())
+			return
+		}
+		if config.Host != "localhost" {
+			t.Errorf("The signed credential file must be loaded")
+			return
+		}
+	})
+
+	// Test signature file
+	t.Run("Test signature file", func(t *testing.T) {
+		if err := SignConfigFile(configFile, privateKey); err != nil {
+			t.Errorf("Error signing configuration file: %s", err.Error())
+			return
+		}
+		info, err := os.Stat(configFile + signatureExtension)
+		if err != nil {
+			t.Errorf("Error reading signature file: %s", err.Error())
+			return
+		}
+		if runtime.GOOS != "windows" && info.Mode().Perm() != 0600 {
+			t.Errorf("The signature file mode must be 0600, got %04o", info.Mode().Perm())
+			return
+		}
+
+		// the signature cannot replace a directory
+		blockedFile := dirName + "/blocked.json"
+		if err := files.WriteFile(blockedFile, content); err != nil {
+			t.Errorf("Error writing json configuration file: %s", err.Error())
+			return
+		}
+		if err := os.MkdirAll(blockedFile+signatureExtension+"/child", 0700); err != nil {
+			t.Errorf("Error creating directory: %s", err.Error())
+			return
+		}
+		if err := SignConfigFile(blockedFile, privateKey); !errorex.IS(err, ErrorCodeConfigFileWriteError) {
+			t.Errorf("Configuration file write error expected")
+			return
+		}
+	})
+
+	// Test invalid trusted keys
+	t.Run("Test invalid trusted keys", func(t *testing.T) {
+		_, err := ParseTrustedKeys("invalid")
+		if !errorex.IS(err, ErrorCodeTrustedKeysParseError) {
+			t.Errorf("Trusted keys parse error expected")
+			return
+		}
+	})
+
+}
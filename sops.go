@@ -0,0 +1,454 @@
+/*
+ * © 2023 fkmatsuda
+
+ * This file is licensed under the terms of the MIT license. Permission is hereby
+ * granted, free of charge, to any person obtaining a copy of this software and
+ * associated documentation files (the "Software"), to deal in the Software without
+ * restriction, including without limitation the rights to use, copy, modify,
+ * merge, publish, and/or distribute copies of the Software, and to permit persons
+ * to whom the Software is furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS," WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY,
+ * WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+ * CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ *
+ */
+
+package dbconfig
+
+import (
+	"bytes"
+	"crypto/sha512"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"filippo.io/age"
+	"filippo.io/age/armor"
+	"github.com/fkmatsuda-dev/commons/errorex"
+	"github.com/fkmatsuda-dev/commons/files"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// sopsMetadataKey is the document key holding the SOPS metadata
+	sopsMetadataKey = "sops"
+
+	sopsKeyNotLoaded = "SOPS data key not loaded"
+	sopsMacMismatch  = "SOPS MAC mismatch"
+	sopsMetadata     = "SOPS metadata error"
+)
+
+// sopsFileMetadata is the SOPS metadata stored in the encrypted files, only the age key groups are supported
+type sopsFileMetadata struct {
+	Age []struct {
+		Recipient string `json:"recipient"`
+		Enc       string `json:"enc"`
+	} `json:"age"`
+	LastModified      string `json:"lastmodified"`
+	Mac               string `json:"mac"`
+	UnencryptedSuffix string `json:"unencrypted_suffix"`
+	EncryptedSuffix   string `json:"encrypted_suffix"`
+	UnencryptedRegex  string `json:"unencrypted_regex"`
+	EncryptedRegex    string `json:"encrypted_regex"`
+	MacOnlyEncrypted  bool   `json:"mac_only_encrypted"`
+}
+
+// sopsItem is a key and value pair of a SOPS document, the items keep the file order used to compute the MAC
+type sopsItem struct {
+	key   string
+	value interface{}
+}
+
+// sopsBranch is an ordered mapping of a SOPS document
+type sopsBranch []sopsItem
+
+// isSopsDocument checks if a decoded configuration file holds SOPS metadata
+func isSopsDocument(document interface{}) bool {
+	root, ok := document.(map[string]interface{})
+	if !ok {
+		return false
+	}
+	metadata, ok := root[sopsMetadataKey].(map[string]interface{})
+	if !ok {
+		return false
+	}
+	_, ok = metadata["mac"]
+	return ok
+}
+
+// decryptSopsFile decrypts a SOPS encrypted configuration file with the age identities, the MAC of the file is
+// verified before the decrypted document is returned
+func decryptSopsFile(file string, content []byte) (interface{}, error) {
+	var branch sopsBranch
+	var err error
+	switch strings.ToLower(filepath.Ext(file)) {
+	case ".yaml", ".yml":
+		branch, err = parseSopsYAML(content)
+	default:
+		branch, err = parseSopsJSON(content)
+	}
+	if err != nil {
+		return nil, errorex.New(ErrorCodeConfigFileParseError, "Configuration file parse error", err.Error())
+	}
+
+	// split the metadata from the document
+	var metadata sopsFileMetadata
+	var document sopsBranch
+	for _, item := range branch {
+		if item.key != sopsMetadataKey {
+			document = append(document, item)
+			continue
+		}
+		encoded, err := json.Marshal(sopsGeneric(item.value))
+		if err == nil {
+			err = json.Unmarshal(encoded, &metadata)
+		}
+		if err != nil {
+			return nil, errorex.New(ErrorCodeSopsMetadataError, sopsMetadata, err.Error())
+		}
+	}
+
+	dataKey, err := sopsDataKey(metadata)
+	if err != nil {
+		return nil, err
+	}
+
+	decrypter := sopsDecrypter{metadata: metadata, key: dataKey, hash: sha512.New()}
+	decrypted, err := decrypter.walk(document, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := decrypter.verifyMac(); err != nil {
+		return nil, err
+	}
+	return decrypted, nil
+}
+
+// sopsDataKey decrypts the data key of the file with the age identities
+func sopsDataKey(metadata sopsFileMetadata) ([]byte, error) {
+	if len(metadata.Age) == 0 {
+		return nil, errorex.New(ErrorCodeSopsMetadataError, sopsMetadata, "the file has no age recipients")
+	}
+	identities, err := loadAgeIdentities()
+	if err != nil {
+		return nil, err
+	}
+	for _, recipient := range metadata.Age {
+		reader, err := age.Decrypt(armor.NewReader(strings.NewReader(recipient.Enc)), identities...)
+		if err != nil {
+			continue
+		}
+		dataKey, err := io.ReadAll(reader)
+		if err != nil {
+			continue
+		}
+		return dataKey, nil
+	}
+	return nil, errorex.New(
+		ErrorCodeSopsKeyNotLoaded,
+		sopsKeyNotLoaded,
+		"no age identity matches the recipients of the file",
+	)
+}
+
+// loadAgeIdentities loads the age identities from the SOPS_AGE_KEY environment variable, from the file named by
+// SOPS_AGE_KEY_FILE or from the sops/age/keys.txt file of the user configuration directory, like sops does
+func loadAgeIdentities() ([]age.Identity, error) {
+	keys, chk := lookupEnv("SOPS_AGE_KEY")
+	if !chk {
+		keyFile, chkFile := lookupEnv("SOPS_AGE_KEY_FILE")
+		if !chkFile {
+			configDir, err := os.UserConfigDir()
+			if err != nil {
+				return nil, errorex.New(ErrorCodeSopsKeyNotLoaded, sopsKeyNotLoaded, err.Error())
+			}
+			keyFile = filepath.Join(configDir, "sops", "age", "keys.txt")
+		}
+		var err error
+		keys, err = files.ReadFile(keyFile)
+		if err != nil {
+			return nil, errorex.New(ErrorCodeSopsKeyNotLoaded, sopsKeyNotLoaded, err.Error())
+		}
+	}
+	identities, err := age.ParseIdentities(strings.NewReader(keys))
+	if err != nil {
+		return nil, errorex.New(ErrorCodeSopsKeyNotLoaded, sopsKeyNotLoaded, err.Error())
+	}
+	return identities, nil
+}
+
+// sopsDecrypter decrypts the values of a SOPS document while hashing them to compute the MAC
+type sopsDecrypter struct {
+	metadata sopsFileMetadata
+	key      []byte
+	hash     hash.Hash
+}
+
+// walk decrypts a SOPS document value and returns it as a generic JSON value
+func (d *sopsDecrypter) walk(value interface{}, path []string) (interface{}, error) {
+	switch v := value.(type) {
+	case sopsBranch:
+		result := make(map[string]interface{}, len(v))
+		for _, item := range v {
+			child, err := d.walk(item.value, append(path[:len(path):len(path)], item.key))
+			if err != nil {
+				return nil, err
+			}
+			result[item.key] = child
+		}
+		return result, nil
+	case []interface{}:
+		result := make([]interface{}, len(v))
+		for i, item := range v {
+			child, err := d.walk(item, path)
+			if err != nil {
+				return nil, err
+			}
+			result[i] = child
+		}
+		return result, nil
+	}
+
+	encrypted := d.isEncrypted(path)
+	if str, ok := value.(string); ok && encrypted && str != "" {
+		plaintext, valueType, err := decryptValue(str, d.key, []byte(strings.Join(path, ":")+":"))
+		if err != nil {
+			return nil, err
+		}
+		_, _ = d.hash.Write([]byte(plaintext))
+		return typedValue(plaintext, valueType), nil
+	}
+	if encrypted || !d.metadata.MacOnlyEncrypted {
+		_, _ = d.hash.Write(sopsBytes(value))
+	}
+	return value, nil
+}
+
+// isEncrypted checks if the value at the path is encrypted according to the suffix and regex rules of the file
+func (d *sopsDecrypter) isEncrypted(path []string) bool {
+	encrypted := true
+	if suffix := d.metadata.UnencryptedSuffix; suffix != "" {
+		for _, key := range path {
+			if strings.HasSuffix(key, suffix) {
+				encrypted = false
+				break
+			}
+		}
+	}
+	if suffix := d.metadata.EncryptedSuffix; suffix != "" {
+		encrypted = false
+		for _, key := range path {
+			if strings.HasSuffix(key, suffix) {
+				encrypted = true
+				break
+			}
+		}
+	}
+	if expr := d.metadata.UnencryptedRegex; expr != "" {
+		for _, key := range path {
+			if matched, _ := regexp.MatchString(expr, key); matched {
+				encrypted = false
+				break
+			}
+		}
+	}
+	if expr := d.metadata.EncryptedRegex; expr != "" {
+		encrypted = false
+		for _, key := range path {
+			if matched, _ := regexp.MatchString(expr, key); matched {
+				encrypted = true
+				break
+			}
+		}
+	}
+	return encrypted
+}
+
+// verifyMac compares the MAC of the decrypted values with the MAC stored in the file
+func (d *sopsDecrypter) verifyMac() error {
+	lastModified, err := time.Parse(time.RFC3339, d.metadata.LastModified)
+	if err != nil {
+		return errorex.New(ErrorCodeSopsMetadataError, sopsMetadata, err.Error())
+	}
+	mac, _, err := decryptValue(d.metadata.Mac, d.key, []byte(lastModified.Format(time.RFC3339)))
+	if err != nil {
+		return errorex.New(ErrorCodeSopsMacMismatch, sopsMacMismatch, "the file MAC cannot be decrypted")
+	}
+	if !strings.EqualFold(mac, fmt.Sprintf("%X", d.hash.Sum(nil))) {
+		return errorex.New(ErrorCodeSopsMacMismatch, sopsMacMismatch, "the file was modified after it was encrypted")
+	}
+	return nil
+}
+
+// sopsBytes formats a plain value like sops does before hashing it
+func sopsBytes(value interface{}) []byte {
+	switch v := value.(type) {
+	case string:
+		return []byte(v)
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return []byte(strconv.FormatInt(i, 10))
+		}
+		if f, err := v.Float64(); err == nil {
+			return []byte(strconv.FormatFloat(f, 'f', -1, 64))
+		}
+		return []byte(v.String())
+	case bool:
+		if v {
+			return []byte("True")
+		}
+		return []byte("False")
+	}
+	return nil
+}
+
+// sopsGeneric converts a SOPS document value to a generic JSON value
+func sopsGeneric(value interface{}) interface{} {
+	switch v := value.(type) {
+	case sopsBranch:
+		result := make(map[string]interface{}, len(v))
+		for _, item := range v {
+			result[item.key] = sopsGeneric(item.value)
+		}
+		return result
+	case []interface{}:
+		result := make([]interface{}, len(v))
+		for i, item := range v {
+			result[i] = sopsGeneric(item)
+		}
+		return result
+	}
+	return value
+}
+
+// parseSopsJSON decodes a JSON document keeping the order of the keys
+func parseSopsJSON(content []byte) (sopsBranch, error) {
+	decoder := json.NewDecoder(bytes.NewReader(content))
+	decoder.UseNumber()
+	value, err := parseSopsJSONValue(decoder)
+	if err != nil {
+		return nil, err
+	}
+	branch, ok := value.(sopsBranch)
+	if !ok {
+		return nil, errors.New("the document root must be an object")
+	}
+	return branch, nil
+}
+
+func parseSopsJSONValue(decoder *json.Decoder) (interface{}, error) {
+	token, err := decoder.Token()
+	if err != nil {
+		return nil, err
+	}
+	switch token {
+	case json.Delim('{'):
+		branch := sopsBranch{}
+		for decoder.More() {
+			keyToken, err := decoder.Token()
+			if err != nil {
+				return nil, err
+			}
+			key, ok := keyToken.(string)
+			if !ok {
+				return nil, fmt.Errorf("invalid object key %v", keyToken)
+			}
+			value, err := parseSopsJSONValue(decoder)
+			if err != nil {
+				return nil, err
+			}
+			branch = append(branch, sopsItem{key: key, value: value})
+		}
+		if _, err := decoder.Token(); err != nil {
+			return nil, err
+		}
+		return branch, nil
+	case json.Delim('['):
+		list := []interface{}{}
+		for decoder.More() {
+			value, err := parseSopsJSONValue(decoder)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, value)
+		}
+		if _, err := decoder.Token(); err != nil {
+			return nil, err
+		}
+		return list, nil
+	}
+	return token, nil
+}
+
+// parseSopsYAML decodes a YAML document keeping the order of the keys, the comments are ignored
+func parseSopsYAML(content []byte) (sopsBranch, error) {
+	var root yaml.Node
+	if err := yaml.Unmarshal(content, &root); err != nil {
+		return nil, err
+	}
+	if root.Kind != yaml.DocumentNode || len(root.Content) == 0 {
+		return nil, errors.New("the document is empty")
+	}
+	value, err := parseSopsYAMLNode(root.Content[0])
+	if err != nil {
+		return nil, err
+	}
+	branch, ok := value.(sopsBranch)
+	if !ok {
+		return nil, errors.New("the document root must be a mapping")
+	}
+	return branch, nil
+}
+
+func parseSopsYAMLNode(node *yaml.Node) (interface{}, error) {
+	switch node.Kind {
+	case yaml.AliasNode:
+		return parseSopsYAMLNode(node.Alias)
+	case yaml.MappingNode:
+		branch := sopsBranch{}
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			value, err := parseSopsYAMLNode(node.Content[i+1])
+			if err != nil {
+				return nil, err
+			}
+			branch = append(branch, sopsItem{key: node.Content[i].Value, value: value})
+		}
+		return branch, nil
+	case yaml.SequenceNode:
+		list := []interface{}{}
+		for _, child := range node.Content {
+			value, err := parseSopsYAMLNode(child)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, value)
+		}
+		return list, nil
+	}
+	var value interface{}
+	if err := node.Decode(&value); err != nil {
+		return nil, err
+	}
+	switch v := value.(type) {
+	case int:
+		return json.Number(strconv.Itoa(v)), nil
+	case float64:
+		return json.Number(strconv.FormatFloat(v, 'f', -1, 64)), nil
+	}
+	return value, nil
+}
@@ -0,0 +1,185 @@
+/*
+ * © 2023 fkmatsuda
+
+ * This file is licensed under the terms of the MIT license. Permission is hereby
+ * granted, free of charge, to any person obtaining a copy of this software and
+ * associated documentation files (the "Software"), to deal in the Software without
+ * restriction, including without limitation the rights to use, copy, modify,
+ * merge, publish, and/or distribute copies of the Software, and to permit persons
+ * to whom the Software is furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS," WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY,
+ * WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+ * CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ *
+ */
+
+package dbconfig
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"filippo.io/age"
+	"github.com/fkmatsuda-dev/commons/errorex"
+	"github.com/fkmatsuda-dev/commons/files"
+)
+
+// The testdata/sops files are encrypted by the sops 3.9.4 command with the age key of testdata/sops/keys.txt:
+//
+//	sops encrypt --age age19uvkrwfkzhqpm9hl6m32s2qnzpf3z6zjg7paenmyu9fe0pjv3v9swjgwvs dbconfig.json
+const sopsTestdata = "testdata/sops"
+
+// writeSopsFixture writes a copy of a SOPS fixture with the replacements applied to the directory
+func writeSopsFixture(t *testing.T, dirName string, name string, replacements ...string) string {
+	content, err := os.ReadFile(filepath.Join(sopsTestdata, name))
+	if err != nil {
+		t.Fatalf("Error reading SOPS fixture: %s", err.Error())
+	}
+	replaced := strings.NewReplacer(replacements...).Replace(string(content))
+	if replaced == string(content) && len(replacements) > 0 {
+		t.Fatalf("The replacements do not change the SOPS fixture %s", name)
+	}
+	file := filepath.Join(dirName, name)
+	if err := files.WriteFile(file, replaced); err != nil {
+		t.Fatalf("Error writing SOPS fixture: %s", err.Error())
+	}
+	return file
+}
+
+// sopsFixtureValue returns the value of the key in the first line of the SOPS fixture that holds it
+func sopsFixtureValue(t *testing.T, name string, key string) string {
+	content, err := os.ReadFile(filepath.Join(sopsTestdata, name))
+	if err != nil {
+		t.Fatalf("Error reading SOPS fixture: %s", err.Error())
+	}
+	for _, line := range strings.Split(string(content), "\n") {
+		if _, value, ok := strings.Cut(strings.TrimSpace(line), `"`+key+`": `); ok {
+			return strings.TrimSuffix(value, ",")
+		}
+	}
+	t.Fatalf("The %s key was not found in the SOPS fixture %s", key, name)
+	return ""
+}
+
+func TestSopsConfigFile(t *testing.T) {
+
+	// Create a temporary directory inside system temp directory
+	dirName, err := files.CreateTempDir()
+	if err != nil {
+		t.Errorf("Error creating temporary directory: %s", err.Error())
+		return
+	}
+	defer func() {
+		_ = files.CleanupTempDirs()
+	}()
+
+	keyFile := filepath.Join(sopsTestdata, "keys.txt")
+	expected := Config{
+		Type:     DbTypePostgres,
+		Host:     "db.example.com",
+		Port:     5432,
+		User:     "app",
+		Password: "s3cr3t",
+		Database: "orders",
+		SSL: &SSLConfig{
+			Mode: SSLModeVerifyCA,
+			Ca:   "ca.crt",
+		},
+	}
+
+	// Test SOPS json and yaml configuration files
+	for _, name := range []string{"dbconfig.json", "dbconfig.yaml"} {
+		t.Run("Test SOPS configuration file "+name, func(t *testing.T) {
+			t.Setenv("SOPS_AGE_KEY_FILE", keyFile)
+			config, err := LoadConfigFile(filepath.Join(sopsTestdata, name))
+			if err != nil {
+				t.Errorf("Error loading SOPS configuration file: %s", err.Error())
+				return
+			}
+			if !expected.compare(config) {
+				t.Errorf("The configuration %+v expected, got %+v", expected, config)
+				return
+			}
+		})
+	}
+
+	// Test SOPS files with tampered unencrypted value
+	for _, name := range []string{"dbconfig.json", "dbconfig.yaml"} {
+		t.Run("Test SOPS file with tampered unencrypted value "+name, func(t *testing.T) {
+			t.Setenv("SOPS_AGE_KEY_FILE", keyFile)
+			file := writeSopsFixture(t, dirName, name, "managed by sops", "managed by hand")
+			defer func() {
+				_ = os.Remove(file)
+			}()
+
+			_, err := LoadConfigFile(file)
+			if !errorex.IS(err, ErrorCodeSopsMacMismatch) {
+				t.Errorf("SOPS MAC mismatch error expected, got %v", err)
+				return
+			}
+		})
+	}
+
+	// Test SOPS file with swapped encrypted values
+	t.Run("Test SOPS file with swapped encrypted values", func(t *testing.T) {
+		t.Setenv("SOPS_AGE_KEY_FILE", keyFile)
+		host := sopsFixtureValue(t, "dbconfig.json", "host")
+		user := sopsFixtureValue(t, "dbconfig.json", "user")
+		file := writeSopsFixture(t, dirName, "dbconfig.json", host, user, user, host)
+		defer func() {
+			_ = os.Remove(file)
+		}()
+
+		_, err := LoadConfigFile(file)
+		if !errorex.IS(err, ErrorCodeDecryptionError) {
+			t.Errorf("Decryption error expected, got %v", err)
+			return
+		}
+	})
+
+	// Test SOPS file with another age key
+	t.Run("Test SOPS file with another age key", func(t *testing.T) {
+		other, _ := age.GenerateX25519Identity()
+		t.Setenv("SOPS_AGE_KEY", other.String())
+
+		_, err := LoadConfigFile(filepath.Join(sopsTestdata, "dbconfig.json"))
+		if !errorex.IS(err, ErrorCodeSopsKeyNotLoaded) {
+			t.Errorf("SOPS data key not loaded error expected")
+			return
+		}
+	})
+
+	// Test plain yaml configuration file
+	t.Run("Test plain yaml configuration file", func(t *testing.T) {
+		file := dirName + "/dbconfig.yaml"
+		err := files.WriteFile(file, "type: POSTGRESQL\nhost: db.example.com\nport: 5432\nuser: app\npassword: s3cr3t\n"+
+			"database: orders\nssl:\n  mode: verify-ca\n  ca: ca.crt\n")
+		if err != nil {
+			t.Errorf("Error writing yaml configuration file: %s", err.Error())
+			return
+		}
+		defer func() {
+			_ = os.Remove(file)
+		}()
+
+		config, err := LoadConfig(dirName)
+		if err != nil {
+			t.Errorf("Error loading yaml configuration file: %s", err.Error())
+			return
+		}
+		if !expected.compare(config) {
+			t.Errorf("The loaded configuration is different from the expected configuration")
+			return
+		}
+	})
+
+}
@@ -0,0 +1,297 @@
+/*
+ * © 2023 fkmatsuda
+
+ * This file is licensed under the terms of the MIT license. Permission is hereby
+ * granted, free of charge, to any person obtaining a copy of this software and
+ * associated documentation files (the "Software"), to deal in the Software without
+ * restriction, including without limitation the rights to use, copy, modify,
+ * merge, publish, and/or distribute copies of the Software, and to permit persons
+ * to whom the Software is furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS," WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY,
+ * WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+ * CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ *
+ */
+
+package dbconfig
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/fkmatsuda-dev/commons/errorex"
+)
+
+// SqliteMemory is the database name of the SQLite in-memory databases
+const SqliteMemory = ":memory:"
+
+type SqliteJournalMode int8
+
+const (
+	SqliteJournalModeDelete SqliteJournalMode = iota + 1
+	SqliteJournalModeTruncate
+	SqliteJournalModePersist
+	SqliteJournalModeMemory
+	SqliteJournalModeWal
+	SqliteJournalModeOff
+)
+
+var SqliteJournalModeName = map[SqliteJournalMode]string{
+	SqliteJournalModeDelete:   "DELETE",
+	SqliteJournalModeTruncate: "TRUNCATE",
+	SqliteJournalModePersist:  "PERSIST",
+	SqliteJournalModeMemory:   "MEMORY",
+	SqliteJournalModeWal:      "WAL",
+	SqliteJournalModeOff:      "OFF",
+}
+
+var SqliteJournalModeValue = map[string]SqliteJournalMode{
+	SqliteJournalModeName[SqliteJournalModeDelete]:   SqliteJournalModeDelete,
+	SqliteJournalModeName[SqliteJournalModeTruncate]: SqliteJournalModeTruncate,
+	SqliteJournalModeName[SqliteJournalModePersist]:  SqliteJournalModePersist,
+	SqliteJournalModeName[SqliteJournalModeMemory]:   SqliteJournalModeMemory,
+	SqliteJournalModeName[SqliteJournalModeWal]:      SqliteJournalModeWal,
+	SqliteJournalModeName[SqliteJournalModeOff]:      SqliteJournalModeOff,
+}
+
+// String returns the string value of the SqliteJournalMode
+func (s SqliteJournalMode) String() string {
+	return SqliteJournalModeName[s]
+}
+
+// ParseSqliteJournalMode parses the string value to a SqliteJournalMode, the value is case-insensitive like the
+// journal_mode pragma
+func ParseSqliteJournalMode(value string) (SqliteJournalMode, error) {
+	if s, ok := SqliteJournalModeValue[strings.ToUpper(value)]; ok {
+		return s, nil
+	}
+	return SqliteJournalMode(0), errorex.New(
+		ErrorCodeSqliteJournalModeParseError,
+		"SqliteJournalMode parse error",
+		fmt.Sprintf("\"%s\" value for SqliteJournalMode is invalid",
+			value,
+		),
+	)
+}
+
+// MarshalJSON marshals the enum as a quoted json string
+func (s SqliteJournalMode) MarshalJSON() ([]byte, error) {
+	return json.Marshal(s.String())
+}
+
+// UnmarshalJSON unmashals a quoted json string to the enum value
+func (s *SqliteJournalMode) UnmarshalJSON(data []byte) error {
+	var value string
+	if err := json.Unmarshal(data, &value); err != nil {
+		return errorex.New(ErrorCodeSqliteJournalModeParseError, "SqliteJournalMode parse error", err.Error())
+	}
+	parsed, err := ParseSqliteJournalMode(value)
+	if err != nil {
+		return err
+	}
+	*s = parsed
+	return nil
+}
+
+// MarshalText marshals the enum as text, it is used by the toml encoding
+func (s SqliteJournalMode) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// UnmarshalText unmarshals the text to the enum value
+func (s *SqliteJournalMode) UnmarshalText(data []byte) error {
+	parsed, err := ParseSqliteJournalMode(string(data))
+	if err != nil {
+		return err
+	}
+	*s = parsed
+	return nil
+}
+
+// SqliteConfig holds the options applied to the SQLite connections, the zero values keep the SQLite defaults
+type SqliteConfig struct {
+	JournalMode SqliteJournalMode `json:"journalMode,omitempty" toml:"journalMode,omitempty"`
+	// BusyTimeout is the time in milliseconds a connection waits for a locked database
+	BusyTimeout uint32 `json:"busyTimeout,omitempty" toml:"busyTimeout,omitempty"`
+	// ForeignKeys enables the foreign key constraints, which SQLite does not enforce by default
+	ForeignKeys bool `json:"foreignKeys,omitempty" toml:"foreignKeys,omitempty"`
+}
+
+// validate checks the journal mode of the options
+func (s *SqliteConfig) validate() error {
+	if s == nil || s.JournalMode == 0 {
+		return nil
+	}
+	if _, ok := SqliteJournalModeName[s.JournalMode]; !ok {
+		return errorex.New(
+			ErrorCodeConfigInvalid,
+			configInvalid,
+			fmt.Sprintf("\"%d\" is not a valid sqlite journal mode", s.JournalMode),
+		)
+	}
+	return nil
+}
+
+// loadSqliteEnv loads the SQLite settings from DB_DATABASE with the database file (or :memory:) and from the
+// DB_SQLITE_JOURNAL_MODE, DB_SQLITE_BUSY_TIMEOUT and DB_SQLITE_FOREIGN_KEYS variables, the host, port, user, password
+// and SSL variables are ignored
+func loadSqliteEnv(lookup envLookup, config Config) (Config, error) {
+	database, err := envValue(lookup, "DB_DATABASE", config.Database)
+	if err != nil {
+		return Config{}, err
+	}
+	options := SqliteConfig{}
+	if config.Sqlite != nil {
+		options = *config.Sqlite
+	}
+	if strJournalMode, chk := lookup("DB_SQLITE_JOURNAL_MODE"); chk {
+		options.JournalMode, err = ParseSqliteJournalMode(strJournalMode)
+		if err != nil {
+			return Config{}, errorex.New(ErrorCodeEnvConfigParseError, configurationParseError, err.Error())
+		}
+	}
+	if strBusyTimeout, chk := lookup("DB_SQLITE_BUSY_TIMEOUT"); chk {
+		busyTimeout, err := strconv.ParseUint(strBusyTimeout, 10, 32)
+		if err != nil {
+			return Config{}, errorex.New(ErrorCodeEnvConfigParseError, configurationParseError, err.Error())
+		}
+		options.BusyTimeout = uint32(busyTimeout)
+	}
+	if strForeignKeys, chk := lookup("DB_SQLITE_FOREIGN_KEYS"); chk {
+		options.ForeignKeys, err = strconv.ParseBool(strForeignKeys)
+		if err != nil {
+			return Config{}, errorex.New(ErrorCodeEnvConfigParseError, configurationParseError, err.Error())
+		}
+	}
+	config = Config{Type: DbTypeSqlite, Database: database}
+	if options != (SqliteConfig{}) {
+		config.Sqlite = &options
+	}
+	return config, nil
+}
+
+// Sqlite3DSN returns the configuration as a file: URI accepted by the mattn/go-sqlite3 driver, the options are given
+// by its _journal_mode, _busy_timeout and _foreign_keys parameters
+func (c Config) Sqlite3DSN() string {
+	query := url.Values{}
+	if c.Sqlite != nil {
+		setQuery(query, "_journal_mode", c.Sqlite.JournalMode.String())
+		if c.Sqlite.BusyTimeout != 0 {
+			query.Set("_busy_timeout", strconv.FormatUint(uint64(c.Sqlite.BusyTimeout), 10))
+		}
+		if c.Sqlite.ForeignKeys {
+			query.Set("_foreign_keys", "1")
+		}
+	}
+	return sqliteURI(c.Database, query.Encode())
+}
+
+// SqliteDSN returns the configuration as a file: URI accepted by the modernc.org/sqlite driver, the options are given
+// by its _pragma parameters
+func (c Config) SqliteDSN() string {
+	var pragmas []string
+	if c.Sqlite != nil {
+		// the busy timeout is set first so the other pragmas wait for a locked database
+		if c.Sqlite.BusyTimeout != 0 {
+			pragmas = append(pragmas, fmt.Sprintf("_pragma=busy_timeout(%d)", c.Sqlite.BusyTimeout))
+		}
+		if c.Sqlite.JournalMode != 0 {
+			pragmas = append(pragmas, fmt.Sprintf("_pragma=journal_mode(%s)", c.Sqlite.JournalMode))
+		}
+		if c.Sqlite.ForeignKeys {
+			pragmas = append(pragmas, "_pragma=foreign_keys(1)")
+		}
+	}
+	return sqliteURI(c.Database, strings.Join(pragmas, "&"))
+}
+
+// sqliteURI returns the SQLite file: URI of the database file with the query, the characters that delimit the URI
+// parts are escaped in the file name
+func sqliteURI(database string, query string) string {
+	uri := "file:" + strings.NewReplacer("%", "%25", "?", "%3f", "#", "%23").Replace(database)
+	if query != "" {
+		uri += "?" + query
+	}
+	return uri
+}
+
+// sqliteURL returns the configuration as a sqlite: URL, the absolute files have the sqlite:///path form and the
+// relative files and the in-memory databases the sqlite:path form
+func (c Config) sqliteURL() string {
+	query := url.Values{}
+	if c.Sqlite != nil {
+		setQuery(query, "journal_mode", c.Sqlite.JournalMode.String())
+		if c.Sqlite.BusyTimeout != 0 {
+			query.Set("busy_timeout", strconv.FormatUint(uint64(c.Sqlite.BusyTimeout), 10))
+		}
+		if c.Sqlite.ForeignKeys {
+			query.Set("foreign_keys", "true")
+		}
+	}
+	sqliteURL := url.URL{Scheme: URLSchemeName[DbTypeSqlite], RawQuery: query.Encode()}
+	if strings.HasPrefix(c.Database, "/") {
+		sqliteURL.Path = c.Database
+	} else {
+		sqliteURL.Opaque = (&url.URL{Path: c.Database}).EscapedPath()
+	}
+	return sqliteURL.String()
+}
+
+// parseSqliteURL parses the sqlite:path and sqlite:///path URLs, the options are read from the journal_mode,
+// busy_timeout and foreign_keys parameters or from the same parameters of the mattn/go-sqlite3 driver
+func parseSqliteURL(parsed *url.URL) (Config, error) {
+	database := parsed.Host + parsed.Path
+	if parsed.Opaque != "" {
+		var err error
+		database, err = url.PathUnescape(parsed.Opaque)
+		if err != nil {
+			return Config{}, errorex.New(ErrorCodeURLParseError, urlParseError, "the database file is malformed")
+		}
+	}
+	query := parsed.Query()
+	options := SqliteConfig{}
+	if strJournalMode := queryValue(query, "journal_mode", "_journal_mode"); strJournalMode != "" {
+		journalMode, err := ParseSqliteJournalMode(strJournalMode)
+		if err != nil {
+			return Config{}, errorex.New(ErrorCodeURLParseError, urlParseError, err.Error())
+		}
+		options.JournalMode = journalMode
+	}
+	if strBusyTimeout := queryValue(query, "busy_timeout", "_busy_timeout"); strBusyTimeout != "" {
+		busyTimeout, err := strconv.ParseUint(strBusyTimeout, 10, 32)
+		if err != nil {
+			return Config{}, errorex.New(
+				ErrorCodeURLParseError,
+				urlParseError,
+				fmt.Sprintf("\"%s\" value for busy timeout is invalid", strBusyTimeout),
+			)
+		}
+		options.BusyTimeout = uint32(busyTimeout)
+	}
+	if strForeignKeys := queryValue(query, "foreign_keys", "_foreign_keys"); strForeignKeys != "" {
+		foreignKeys, err := strconv.ParseBool(strForeignKeys)
+		if err != nil {
+			return Config{}, errorex.New(
+				ErrorCodeURLParseError,
+				urlParseError,
+				fmt.Sprintf("\"%s\" value for foreign keys is invalid", strForeignKeys),
+			)
+		}
+		options.ForeignKeys = foreignKeys
+	}
+	config := Config{Type: DbTypeSqlite, Database: database}
+	if options != (SqliteConfig{}) {
+		config.Sqlite = &options
+	}
+	return config, nil
+}
@@ -0,0 +1,251 @@
+/*
+ * © 2023 fkmatsuda
+
+ * This file is licensed under the terms of the MIT license. Permission is hereby
+ * granted, free of charge, to any person obtaining a copy of this software and
+ * associated documentation files (the "Software"), to deal in the Software without
+ * restriction, including without limitation the rights to use, copy, modify,
+ * merge, publish, and/or distribute copies of the Software, and to permit persons
+ * to whom the Software is furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS," WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY,
+ * WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+ * CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ *
+ */
+
+package dbconfig
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/fkmatsuda-dev/commons/errorex"
+	"github.com/fkmatsuda-dev/commons/files"
+)
+
+func TestSqlite(t *testing.T) {
+
+	// Create a temporary directory inside system temp directory
+	dirName, err := files.CreateTempDir()
+	if err != nil {
+		t.Errorf("Error creating temporary directory: %s", err.Error())
+		return
+	}
+	defer func() {
+		_ = files.CleanupTempDirs()
+	}()
+
+	expected := Config{
+		Type:     DbTypeSqlite,
+		Database: "/var/lib/app/app.db",
+		Sqlite: &SqliteConfig{
+			JournalMode: SqliteJournalModeWal,
+			BusyTimeout: 5000,
+			ForeignKeys: true,
+		},
+	}
+
+	// Test load by environment variables
+	t.Run("Test load by environment variables", func(t *testing.T) {
+		t.Setenv("DB_TYPE", "SQLITE")
+		t.Setenv("DB_DATABASE", "/var/lib/app/app.db")
+		t.Setenv("DB_SQLITE_JOURNAL_MODE", "wal")
+		t.Setenv("DB_SQLITE_BUSY_TIMEOUT", "5000")
+		t.Setenv("DB_SQLITE_FOREIGN_KEYS", "true")
+
+		// the host, user and password are not required
+		config, err := LoadFromEnv()
+		if err != nil {
+			t.Errorf("Error loading configuration: %s", err.Error())
+			return
+		}
+		if !expected.compare(config) {
+			t.Errorf("The configuration %+v expected, got %+v", expected, config)
+			return
+		}
+		if err := config.Validate(); err != nil {
+			t.Errorf("Error validating configuration: %s", err.Error())
+			return
+		}
+	})
+
+	// Test load in-memory database by environment variables
+	t.Run("Test load in-memory database by environment variables", func(t *testing.T) {
+		t.Setenv("DB_TYPE", "SQLITE")
+		t.Setenv("DB_DATABASE", SqliteMemory)
+
+		config, err := LoadFromEnv()
+		if err != nil {
+			t.Errorf("Error loading configuration: %s", err.Error())
+			return
+		}
+		if config.Database != SqliteMemory || config.Port != 0 || config.Sqlite != nil || config.SSL != nil {
+			t.Errorf("The in-memory database without options expected, got %+v", config)
+			return
+		}
+	})
+
+	// Test invalid environment variables
+	t.Run("Test invalid environment variables", func(t *testing.T) {
+		invalid := map[string]string{
+			"DB_SQLITE_JOURNAL_MODE": "fast",
+			"DB_SQLITE_BUSY_TIMEOUT": "-1",
+			"DB_SQLITE_FOREIGN_KEYS": "maybe",
+		}
+		for name, value := range invalid {
+			t.Setenv("DB_TYPE", "SQLITE")
+			t.Setenv("DB_DATABASE", "app.db")
+			t.Setenv(name, value)
+			_, err := LoadFromEnv()
+			if !errorex.IS(err, ErrorCodeEnvConfigParseError) {
+				t.Errorf("Environment configuration parse error expected for %s", name)
+			}
+			t.Setenv(name, "")
+		}
+	})
+
+	// Test load configuration file
+	t.Run("Test load configuration file", func(t *testing.T) {
+		configFile := dirName + "/dbconfig.json"
+		err := files.WriteFile(configFile, `{"type": "SQLITE", "database": "/var/lib/app/app.db", `+
+			`"sqlite": {"journalMode": "WAL", "busyTimeout": 5000, "foreignKeys": true}}`)
+		if err != nil {
+			t.Errorf("Error writing configuration file: %s", err.Error())
+			return
+		}
+		config, err := LoadConfigFile(configFile)
+		if err != nil {
+			t.Errorf("Error loading configuration: %s", err.Error())
+			return
+		}
+		if !expected.compare(config) {
+			t.Errorf("The configuration %+v expected, got %+v", expected, config)
+			return
+		}
+	})
+
+	// Test validate
+	t.Run("Test validate", func(t *testing.T) {
+		if err := (Config{Type: DbTypeSqlite}).Validate(); !errorex.IS(err, ErrorCodeConfigInvalid) {
+			t.Errorf("Configuration invalid error expected without database file")
+			return
+		}
+		config := Config{Type: DbTypeSqlite, Database: "app.db", Sqlite: &SqliteConfig{JournalMode: 42}}
+		if err := config.Validate(); !errorex.IS(err, ErrorCodeConfigInvalid) {
+			t.Errorf("Configuration invalid error expected for the invalid journal mode")
+			return
+		}
+	})
+
+	// Test DSN
+	t.Run("Test DSN", func(t *testing.T) {
+		dsns := map[string]string{
+			"sqlite3": "file:/var/lib/app/app.db?_busy_timeout=5000&_foreign_keys=1&_journal_mode=WAL",
+			"sqlite": "file:/var/lib/app/app.db?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)" +
+				"&_pragma=foreign_keys(1)",
+		}
+		for driver, dsn := range dsns {
+			value, err := expected.DSN(driver)
+			if err != nil {
+				t.Errorf("Error building %s DSN: %s", driver, err.Error())
+				return
+			}
+			if value != dsn {
+				t.Errorf("The %s DSN %s expected, got %s", driver, dsn, value)
+				return
+			}
+		}
+		memory := Config{Type: DbTypeSqlite, Database: SqliteMemory}
+		if dsn := memory.Sqlite3DSN(); dsn != "file::memory:" {
+			t.Errorf("The in-memory DSN file::memory: expected, got %s", dsn)
+			return
+		}
+		if _, err := expected.DSN("pgx"); !errorex.IS(err, ErrorCodeDSNError) {
+			t.Errorf("DSN error expected for the pgx driver")
+			return
+		}
+	})
+
+	// Test URL round trip
+	t.Run("Test URL round trip", func(t *testing.T) {
+		configs := map[string]Config{
+			"sqlite:///var/lib/app/app.db?busy_timeout=5000&foreign_keys=true&journal_mode=WAL": expected,
+			"sqlite::memory:":         {Type: DbTypeSqlite, Database: SqliteMemory},
+			"sqlite:data/my%20app.db": {Type: DbTypeSqlite, Database: "data/my app.db"},
+		}
+		for rawURL, config := range configs {
+			value, err := config.URL()
+			if err != nil {
+				t.Errorf("Error building URL: %s", err.Error())
+				return
+			}
+			if value != rawURL {
+				t.Errorf("The URL %s expected, got %s", rawURL, value)
+				return
+			}
+			parsed, err := ParseURL(value)
+			if err != nil {
+				t.Errorf("Error parsing URL: %s", err.Error())
+				return
+			}
+			if !config.compare(parsed) {
+				t.Errorf("The configuration %+v expected, got %+v", config, parsed)
+				return
+			}
+		}
+
+		// the mattn/go-sqlite3 parameters are accepted
+		parsed, err := ParseURL("sqlite3://data/app.db?_journal_mode=wal&_foreign_keys=1")
+		if err != nil {
+			t.Errorf("Error parsing URL: %s", err.Error())
+			return
+		}
+		if parsed.Database != "data/app.db" || parsed.Sqlite == nil || parsed.Sqlite.JournalMode != SqliteJournalModeWal ||
+			!parsed.Sqlite.ForeignKeys {
+			t.Errorf("Unexpected configuration %+v", parsed)
+			return
+		}
+	})
+
+	// Test environment variables of the configuration
+	t.Run("Test environment variables of the configuration", func(t *testing.T) {
+		variables := []string{
+			"DB_TYPE=SQLITE",
+			"DB_DATABASE=/var/lib/app/app.db",
+			"DB_SQLITE_JOURNAL_MODE=WAL",
+			"DB_SQLITE_BUSY_TIMEOUT=5000",
+			"DB_SQLITE_FOREIGN_KEYS=true",
+		}
+		if vars := expected.EnvVars(); !reflect.DeepEqual(vars, variables) {
+			t.Errorf("The variables %v expected, got %v", variables, vars)
+			return
+		}
+		vars, err := expected.Encode("env")
+		if err != nil {
+			t.Errorf("Error encoding configuration: %s", err.Error())
+			return
+		}
+		envFile := dirName + "/sqlite.env"
+		if err := files.WriteFile(envFile, string(vars)); err != nil {
+			t.Errorf("Error writing environment file: %s", err.Error())
+			return
+		}
+		config, err := LoadEnvFile(envFile)
+		if err != nil {
+			t.Errorf("Error loading environment file: %s", err.Error())
+			return
+		}
+		if !expected.compare(config) {
+			t.Errorf("The configuration %+v expected, got %+v", expected, config)
+			return
+		}
+	})
+
+}
@@ -0,0 +1,101 @@
+/*
+ * © 2023 fkmatsuda
+
+ * This file is licensed under the terms of the MIT license. Permission is hereby
+ * granted, free of charge, to any person obtaining a copy of this software and
+ * associated documentation files (the "Software"), to deal in the Software without
+ * restriction, including without limitation the rights to use, copy, modify,
+ * merge, publish, and/or distribute copies of the Software, and to permit persons
+ * to whom the Software is furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS," WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY,
+ * WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+ * CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ *
+ */
+
+package dbconfig
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/fkmatsuda-dev/commons/errorex"
+)
+
+const tlsConfigError = "TLS configuration error"
+
+// TLSConfig returns the crypto/tls configuration of the SSL settings for the clients configured with a tls.Config,
+// like go-redis or the mysql.RegisterTLSConfig configurations, it is nil when the SSL mode is disable or not set. The
+// allow, prefer and require modes do not verify the server certificate, the verify-ca mode verifies it with the ssl
+// ca (or the system roots) and the verify-full mode also verifies that it matches the host
+func (c Config) TLSConfig() (*tls.Config, error) {
+	if c.SSL == nil || c.SSL.Mode == SSLModeDisable {
+		return nil, nil
+	}
+	config := &tls.Config{ServerName: c.Host, MinVersion: tls.VersionTLS12}
+	if c.SSL.Ca != "" {
+		content, err := os.ReadFile(c.SSL.Ca)
+		if err != nil {
+			return nil, errorex.New(ErrorCodeTLSConfigError, tlsConfigError, err.Error())
+		}
+		config.RootCAs = x509.NewCertPool()
+		if !config.RootCAs.AppendCertsFromPEM(content) {
+			return nil, errorex.New(
+				ErrorCodeTLSConfigError,
+				tlsConfigError,
+				fmt.Sprintf("%s has no PEM certificates", c.SSL.Ca),
+			)
+		}
+	}
+	if c.SSL.Cert != "" {
+		certificate, err := tls.LoadX509KeyPair(c.SSL.Cert, c.SSL.Key)
+		if err != nil {
+			return nil, errorex.New(ErrorCodeTLSConfigError, tlsConfigError, err.Error())
+		}
+		config.Certificates = []tls.Certificate{certificate}
+	}
+	switch c.SSL.Mode {
+	case SSLModeVerifyFull:
+	case SSLModeVerifyCA:
+		// the chain is verified without the host name
+		config.InsecureSkipVerify = true
+		config.VerifyPeerCertificate = verifyChain(config.RootCAs)
+	default:
+		config.InsecureSkipVerify = true
+	}
+	return config, nil
+}
+
+// verifyChain returns a tls.Config.VerifyPeerCertificate function that verifies the certificate chain of the server
+// with the roots, or with the system roots when they are nil
+func verifyChain(roots *x509.CertPool) func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+	return func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+		if len(rawCerts) == 0 {
+			return errors.New("the server has no certificate")
+		}
+		certificates := make([]*x509.Certificate, len(rawCerts))
+		for i, raw := range rawCerts {
+			certificate, err := x509.ParseCertificate(raw)
+			if err != nil {
+				return err
+			}
+			certificates[i] = certificate
+		}
+		intermediates := x509.NewCertPool()
+		for _, certificate := range certificates[1:] {
+			intermediates.AddCert(certificate)
+		}
+		_, err := certificates[0].Verify(x509.VerifyOptions{Roots: roots, Intermediates: intermediates})
+		return err
+	}
+}
@@ -0,0 +1,170 @@
+/*
+ * © 2023 fkmatsuda
+
+ * This file is licensed under the terms of the MIT license. Permission is hereby
+ * granted, free of charge, to any person obtaining a copy of this software and
+ * associated documentation files (the "Software"), to deal in the Software without
+ * restriction, including without limitation the rights to use, copy, modify,
+ * merge, publish, and/or distribute copies of the Software, and to permit persons
+ * to whom the Software is furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS," WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY,
+ * WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+ * CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ *
+ */
+
+package dbconfig
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/fkmatsuda-dev/commons/errorex"
+	"github.com/fkmatsuda-dev/commons/files"
+)
+
+// writeCertificate writes a self-signed certificate of the host and its key to the directory and returns the file
+// names and the DER certificate
+func writeCertificate(t *testing.T, dir string, host string) (string, string, []byte) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Error generating key: %s", err.Error())
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: host},
+		DNSNames:              []string{host},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("Error creating certificate: %s", err.Error())
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("Error marshaling key: %s", err.Error())
+	}
+	certFile, keyFile := dir+"/"+host+".crt", dir+"/"+host+".key"
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	if err := files.WriteFile(certFile, string(certPEM)); err != nil {
+		t.Fatalf("Error writing certificate: %s", err.Error())
+	}
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+	if err := files.WriteFile(keyFile, string(keyPEM)); err != nil {
+		t.Fatalf("Error writing key: %s", err.Error())
+	}
+	return certFile, keyFile, der
+}
+
+func TestTLSConfig(t *testing.T) {
+
+	// Create a temporary directory inside system temp directory
+	dirName, err := files.CreateTempDir()
+	if err != nil {
+		t.Errorf("Error creating temporary directory: %s", err.Error())
+		return
+	}
+	defer func() {
+		_ = files.CleanupTempDirs()
+	}()
+
+	caFile, _, serverDER := writeCertificate(t, dirName, "redis.internal")
+	certFile, keyFile, otherDER := writeCertificate(t, dirName, "client.internal")
+
+	// Test disabled
+	t.Run("Test disabled", func(t *testing.T) {
+		for _, ssl := range []*SSLConfig{nil, {Mode: SSLModeDisable}} {
+			config, err := Config{Type: DbTypeRedis, Host: "localhost", SSL: ssl}.TLSConfig()
+			if err != nil || config != nil {
+				t.Errorf("No TLS configuration expected for %+v", ssl)
+				return
+			}
+		}
+	})
+
+	// Test modes
+	t.Run("Test modes", func(t *testing.T) {
+		modes := map[SSLMode]bool{
+			SSLModePrefer:     true,
+			SSLModeRequire:    true,
+			SSLModeVerifyCA:   true,
+			SSLModeVerifyFull: false,
+		}
+		for mode, insecure := range modes {
+			ssl := &SSLConfig{Mode: mode}
+			config, err := Config{Type: DbTypeRedis, Host: "redis.example.com", SSL: ssl}.TLSConfig()
+			if err != nil {
+				t.Errorf("Error building TLS configuration: %s", err.Error())
+				return
+			}
+			if config.InsecureSkipVerify != insecure || config.ServerName != "redis.example.com" ||
+				config.MinVersion != tls.VersionTLS12 {
+				t.Errorf("Unexpected TLS configuration for the %s mode", mode)
+				return
+			}
+		}
+	})
+
+	// Test verify-ca
+	t.Run("Test verify-ca", func(t *testing.T) {
+		config, err := Config{
+			Type: DbTypeRedis,
+			Host: "10.0.0.1",
+			SSL:  &SSLConfig{Mode: SSLModeVerifyCA, Ca: caFile, Cert: certFile, Key: keyFile},
+		}.TLSConfig()
+		if err != nil {
+			t.Errorf("Error building TLS configuration: %s", err.Error())
+			return
+		}
+		if len(config.Certificates) != 1 || config.RootCAs == nil {
+			t.Errorf("The client certificate and the ca expected")
+			return
+		}
+		// the chain is verified even though the host does not match
+		if err := config.VerifyPeerCertificate([][]byte{serverDER}, nil); err != nil {
+			t.Errorf("Error verifying the server certificate: %s", err.Error())
+			return
+		}
+		if err := config.VerifyPeerCertificate([][]byte{otherDER}, nil); err == nil {
+			t.Errorf("Error expected for the certificate of another ca")
+			return
+		}
+	})
+
+	// Test invalid files
+	t.Run("Test invalid files", func(t *testing.T) {
+		invalid := []SSLConfig{
+			{Mode: SSLModeVerifyFull, Ca: dirName + "/missing.crt"},
+			{Mode: SSLModeVerifyFull, Ca: keyFile},
+			{Mode: SSLModeVerifyFull, Ca: caFile, Cert: certFile, Key: caFile},
+		}
+		for _, ssl := range invalid {
+			ssl := ssl
+			_, err := Config{Type: DbTypeRedis, Host: "localhost", SSL: &ssl}.TLSConfig()
+			if !errorex.IS(err, ErrorCodeTLSConfigError) {
+				t.Errorf("TLS configuration error expected for %+v", ssl)
+				return
+			}
+		}
+	})
+}
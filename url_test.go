@@ -0,0 +1,121 @@
+/*
+ * © 2023 fkmatsuda
+
+ * This file is licensed under the terms of the MIT license. Permission is hereby
+ * granted, free of charge, to any person obtaining a copy of this software and
+ * associated documentation files (the "Software"), to deal in the Software without
+ * restriction, including without limitation the rights to use, copy, modify,
+ * merge, publish, and/or distribute copies of the Software, and to permit persons
+ * to whom the Software is furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS," WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY,
+ * WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+ * CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ *
+ */
+
+package dbconfig
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/fkmatsuda-dev/commons/errorex"
+)
+
+func TestURL(t *testing.T) {
+
+	// Test parse PostgreSQL URL
+	t.Run("Test parse PostgreSQL URL", func(t *testing.T) {
+		config, err := ParseURL("postgresql://app:p%40ss@[::1]:6432/orders?sslmode=verify-full" +
+			"&sslrootcert=/etc/ssl/ca.crt&sslcert=/etc/ssl/client.crt&sslkey=/etc/ssl/client.key")
+		if err != nil {
+			t.Errorf("Error parsing URL: %s", err.Error())
+			return
+		}
+		expected := Config{
+			Type:     DbTypePostgres,
+			Host:     "::1",
+			Port:     6432,
+			User:     "app",
+			Password: "p@ss",
+			Database: "orders",
+			SSL: &SSLConfig{
+				Mode: SSLModeVerifyFull,
+				Ca:   "/etc/ssl/ca.crt",
+				Cert: "/etc/ssl/client.crt",
+				Key:  "/etc/ssl/client.key",
+			},
+		}
+		if !expected.compare(config) {
+			t.Errorf("The configuration %+v expected, got %+v", expected, config)
+			return
+		}
+	})
+
+	// Test parse URL defaults
+	t.Run("Test parse URL defaults", func(t *testing.T) {
+		config, err := ParseURL("cockroachdb://root@/defaultdb?host=/var/run/cockroach")
+		if err != nil {
+			t.Errorf("Error parsing URL: %s", err.Error())
+			return
+		}
+		if config.Type != DbTypeCockroachdb || config.Port != 26257 || config.Host != "/var/run/cockroach" ||
+			config.SSL != nil {
+			t.Errorf("Unexpected configuration %+v", config)
+			return
+		}
+	})
+
+	// Test URL round trip
+	t.Run("Test URL round trip", func(t *testing.T) {
+		configs := []Config{
+			{Type: DbTypePostgres, Host: "db", Port: 5432, User: "app", Password: "a b/c", Database: "orders"},
+			{Type: DbTypeCockroachdb, Host: "db", Port: 26257, User: "root", Database: "defaultdb",
+				SSL: &SSLConfig{Mode: SSLModeRequire}},
+			{Type: DbTypeMysql, Host: "db", Port: 3306, User: "app", Password: "secret", Database: "orders",
+				SSL: &SSLConfig{Mode: SSLModeVerifyCA, Ca: "/etc/mysql/ca.pem"}},
+		}
+		for _, config := range configs {
+			databaseURL, err := config.URL()
+			if err != nil {
+				t.Errorf("Error building URL: %s", err.Error())
+				continue
+			}
+			parsed, err := ParseURL(databaseURL)
+			if err != nil {
+				t.Errorf("Error parsing URL %s: %s", databaseURL, err.Error())
+				continue
+			}
+			if !config.compare(parsed) {
+				t.Errorf("The URL %s must be parsed to %+v, got %+v", databaseURL, config, parsed)
+			}
+		}
+	})
+
+	// Test invalid URLs
+	t.Run("Test invalid URLs", func(t *testing.T) {
+		for _, invalid := range []string{
+			"db2://app:secret@db/orders",
+			"postgres://app:secret@db:99999/orders",
+			"mysql://app:secret@db/orders?ssl-mode=ALWAYS",
+			"postgres://app:se cret@db:5432:5432/orders",
+		} {
+			_, err := ParseURL(invalid)
+			if !errorex.IS(err, ErrorCodeURLParseError) {
+				t.Errorf("The URL parse error expected for %s", invalid)
+				continue
+			}
+			if strings.Contains(err.(errorex.EX).Detail(), "secret") {
+				t.Errorf("The password must not be in the error detail")
+			}
+		}
+	})
+
+}
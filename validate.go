@@ -0,0 +1,157 @@
+/*
+ * © 2023 fkmatsuda
+
+ * This file is licensed under the terms of the MIT license. Permission is hereby
+ * granted, free of charge, to any person obtaining a copy of this software and
+ * associated documentation files (the "Software"), to deal in the Software without
+ * restriction, including without limitation the rights to use, copy, modify,
+ * merge, publish, and/or distribute copies of the Software, and to permit persons
+ * to whom the Software is furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS," WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY,
+ * WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+ * CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ *
+ */
+
+package dbconfig
+
+import (
+	"fmt"
+
+	"github.com/fkmatsuda-dev/commons/errorex"
+)
+
+const configInvalid = "Configuration invalid"
+
+// Validate checks that the configuration has the settings required to connect to the database:
+// a valid type, the host, port, user, password (or password command) and database, and the certificates required by
+// the SSL mode. The types without server, with their own connect targets or with servers without authentication
+// require less, see the Database types section of the README
+func (c Config) Validate() error {
+	return c.validate()
+}
+
+// validate checks the configuration like Validate without requiring the optional fields, the sources with their own
+// authentication defaults like the libpq environment use it to accept the configurations without password
+func (c Config) validate(optionalFields ...string) error {
+	if _, ok := DbTypeName[c.Type]; !ok {
+		return errorex.New(ErrorCodeConfigInvalid, configInvalid, fmt.Sprintf("\"%d\" is not a valid database type", c.Type))
+	}
+	// SQLite only requires the database file (or :memory:)
+	if c.Type == DbTypeSqlite {
+		if c.Database == "" {
+			return errorex.New(ErrorCodeConfigInvalid, configInvalid, "database is required")
+		}
+		return c.Sqlite.validate()
+	}
+	optional := map[string]bool{}
+	for _, name := range optionalFields {
+		optional[name] = true
+	}
+	// Oracle connects to exactly one of the service name, SID or TNS alias instead of the database, the TNS alias also
+	// gives the host and port
+	if c.Type == DbTypeOracle {
+		if err := c.Oracle.validate(); err != nil {
+			return err
+		}
+		optional["database"] = true
+		if c.Oracle.TNSAlias != "" {
+			optional["host"], optional["port"] = true, true
+		}
+	}
+	// MongoDB and Redis have the servers without authentication and ClickHouse the default user and database
+	if c.Type == DbTypeMongodb {
+		if err := c.validateMongodb(); err != nil {
+			return err
+		}
+		optional["user"], optional["password"], optional["database"] = true, true, true
+	}
+	if c.Type == DbTypeRedis {
+		if err := c.validateRedis(); err != nil {
+			return err
+		}
+		optional["user"], optional["password"], optional["database"] = true, true, true
+	}
+	if c.Type == DbTypeClickhouse {
+		if err := c.validateClickhouse(); err != nil {
+			return err
+		}
+		optional["user"], optional["password"], optional["database"] = true, true, true
+	}
+	required := []struct {
+		name  string
+		value string
+	}{
+		{"host", c.Host},
+		{"user", c.User},
+		{"password", c.Password + c.PasswordCommand},
+		{"database", c.Database},
+	}
+	for _, field := range required {
+		if field.value == "" && !optional[field.name] {
+			return errorex.New(ErrorCodeConfigInvalid, configInvalid, fmt.Sprintf("%s is required", field.name))
+		}
+	}
+	if c.Port == 0 && !optional["port"] {
+		return errorex.New(ErrorCodeConfigInvalid, configInvalid, "port is required")
+	}
+	// go-mssqldb has no client certificate authentication and go-ora only uses the system roots
+	if c.Type == DbTypeSqlserver && c.SSL != nil && c.SSL.Cert != "" {
+		return errorex.New(ErrorCodeConfigInvalid, configInvalid, "ssl cert and key are not supported by SQLSERVER")
+	}
+	if c.Type == DbTypeOracle && c.SSL != nil && c.SSL.Ca+c.SSL.Cert+c.SSL.Key != "" {
+		return errorex.New(ErrorCodeConfigInvalid, configInvalid, "ssl files are not supported by ORACLE")
+	}
+	if err := c.Yugabyte.validate(); err != nil {
+		return err
+	}
+	return c.SSL.validate(c.Type)
+}
+
+// systemRoots returns true for the database types whose drivers verify the server certificate with the system roots
+// when the ssl ca is not set, like go-mssqldb, go-ora, the MongoDB drivers and Config.TLSConfig for Redis and
+// ClickHouse
+func (s DbType) systemRoots() bool {
+	switch s {
+	case DbTypeSqlserver, DbTypeOracle, DbTypeMongodb, DbTypeRedis, DbTypeClickhouse:
+		return true
+	}
+	return false
+}
+
+// optionalCredentials returns true for the database types whose servers can run without authentication, or like
+// ClickHouse have a default user, their user and password are not required
+func (s DbType) optionalCredentials() bool {
+	return s == DbTypeMongodb || s == DbTypeRedis || s == DbTypeClickhouse
+}
+
+// clientCertificates returns false for the database types whose drivers have no client certificates, like go-mssqldb
+// and go-ora
+func (s DbType) clientCertificates() bool {
+	return s != DbTypeSqlserver && s != DbTypeOracle
+}
+
+// validate checks that the certificates required by the SSL mode are set, the ca is not required by the database
+// types that verify the server certificate with the system roots
+func (s *SSLConfig) validate(dbType DbType) error {
+	if s == nil {
+		return nil
+	}
+	if _, ok := SSLModeName[s.Mode]; !ok {
+		return errorex.New(ErrorCodeConfigInvalid, configInvalid, fmt.Sprintf("\"%d\" is not a valid ssl mode", s.Mode))
+	}
+	if (s.Mode == SSLModeVerifyCA || s.Mode == SSLModeVerifyFull) && s.Ca == "" && !dbType.systemRoots() {
+		return errorex.New(ErrorCodeConfigInvalid, configInvalid, fmt.Sprintf("ssl ca is required by the %s mode", s.Mode))
+	}
+	if (s.Cert == "") != (s.Key == "") {
+		return errorex.New(ErrorCodeConfigInvalid, configInvalid, "ssl cert and key must be set together")
+	}
+	return nil
+}
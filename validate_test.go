@@ -0,0 +1,87 @@
+/*
+ * © 2023 fkmatsuda
+
+ * This file is licensed under the terms of the MIT license. Permission is hereby
+ * granted, free of charge, to any person obtaining a copy of this software and
+ * associated documentation files (the "Software"), to deal in the Software without
+ * restriction, including without limitation the rights to use, copy, modify,
+ * merge, publish, and/or distribute copies of the Software, and to permit persons
+ * to whom the Software is furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS," WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY,
+ * WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+ * CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ *
+ */
+
+package dbconfig
+
+import (
+	"testing"
+
+	"github.com/fkmatsuda-dev/commons/errorex"
+)
+
+func TestValidate(t *testing.T) {
+
+	valid := Config{
+		Type:     DbTypePostgres,
+		Host:     "localhost",
+		Port:     5432,
+		User:     "postgres",
+		Password: "postgres",
+		Database: "postgres",
+		SSL: &SSLConfig{
+			Mode: SSLModeVerifyCA,
+			Ca:   "ca.crt",
+		},
+	}
+
+	// Test valid configuration
+	t.Run("Test valid configuration", func(t *testing.T) {
+		if err := valid.Validate(); err != nil {
+			t.Errorf("Error validating configuration: %s", err.Error())
+			return
+		}
+	})
+
+	// Test valid configuration with password command
+	t.Run("Test valid configuration with password command", func(t *testing.T) {
+		config := valid
+		config.Password = ""
+		config.PasswordCommand = "pass show db/app"
+		if err := config.Validate(); err != nil {
+			t.Errorf("Error validating configuration: %s", err.Error())
+			return
+		}
+	})
+
+	// Test invalid configurations
+	t.Run("Test invalid configurations", func(t *testing.T) {
+		invalid := map[string]func(c *Config){
+			"invalid type":     func(c *Config) { c.Type = 0 },
+			"without host":     func(c *Config) { c.Host = "" },
+			"without port":     func(c *Config) { c.Port = 0 },
+			"without user":     func(c *Config) { c.User = "" },
+			"without password": func(c *Config) { c.Password = "" },
+			"without database": func(c *Config) { c.Database = "" },
+			"without ssl ca":   func(c *Config) { c.SSL = &SSLConfig{Mode: SSLModeVerifyFull} },
+			"without ssl key":  func(c *Config) { c.SSL = &SSLConfig{Mode: SSLModeRequire, Cert: "client.crt"} },
+			"invalid ssl mode": func(c *Config) { c.SSL = &SSLConfig{} },
+		}
+		for name, change := range invalid {
+			config := valid
+			change(&config)
+			if err := config.Validate(); !errorex.IS(err, ErrorCodeConfigInvalid) {
+				t.Errorf("Configuration invalid error expected for the configuration %s", name)
+			}
+		}
+	})
+
+}
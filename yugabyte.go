@@ -0,0 +1,125 @@
+/*
+ * © 2023 fkmatsuda
+
+ * This file is licensed under the terms of the MIT license. Permission is hereby
+ * granted, free of charge, to any person obtaining a copy of this software and
+ * associated documentation files (the "Software"), to deal in the Software without
+ * restriction, including without limitation the rights to use, copy, modify,
+ * merge, publish, and/or distribute copies of the Software, and to permit persons
+ * to whom the Software is furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS," WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY,
+ * WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+ * CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ *
+ */
+
+package dbconfig
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+
+	"github.com/fkmatsuda-dev/commons/errorex"
+)
+
+// YugabyteConfig holds the load balancing options of the YugabyteDB smart drivers, the other PostgreSQL drivers do not
+// accept them
+type YugabyteConfig struct {
+	// LoadBalance spreads the connections over the servers of the cluster
+	LoadBalance bool `json:"loadBalance,omitempty" toml:"loadBalance,omitempty"`
+	// TopologyKeys are the comma separated cloud.region.zone placements preferred by the load balancing, like
+	// aws.us-east-1.us-east-1a,aws.us-east-1.*
+	TopologyKeys string `json:"topologyKeys,omitempty" toml:"topologyKeys,omitempty"`
+}
+
+// validate checks that the topology keys are only set with the load balancing
+func (y *YugabyteConfig) validate() error {
+	if y != nil && y.TopologyKeys != "" && !y.LoadBalance {
+		return errorex.New(ErrorCodeConfigInvalid, configInvalid, "yugabyte topology keys require the load balancing")
+	}
+	return nil
+}
+
+// loadYugabyteEnv loads the YugabyteDB options from the DB_YUGABYTE_LOAD_BALANCE and DB_YUGABYTE_TOPOLOGY_KEYS
+// variables
+func loadYugabyteEnv(lookup envLookup, config Config) (Config, error) {
+	options := YugabyteConfig{}
+	if config.Yugabyte != nil {
+		options = *config.Yugabyte
+	}
+	if strLoadBalance, chk := lookup("DB_YUGABYTE_LOAD_BALANCE"); chk {
+		loadBalance, err := strconv.ParseBool(strLoadBalance)
+		if err != nil {
+			return Config{}, errorex.New(ErrorCodeEnvConfigParseError, configurationParseError, err.Error())
+		}
+		options.LoadBalance = loadBalance
+	}
+	if topologyKeys, chk := lookup("DB_YUGABYTE_TOPOLOGY_KEYS"); chk {
+		options.TopologyKeys = topologyKeys
+	}
+	config.Yugabyte = nil
+	if options != (YugabyteConfig{}) {
+		config.Yugabyte = &options
+	}
+	return config, nil
+}
+
+// postgresQuery returns the parameters of the PostgreSQL family DSNs: the libpq SSL parameters and the Unix-domain
+// socket directory
+func (c Config) postgresQuery() url.Values {
+	query := c.libpqSSLQuery()
+	// the Unix-domain socket directories are not valid URL hosts, libpq, pgx and lib/pq read them from the host and
+	// port parameters
+	if isSocketDir(c.Host) {
+		query.Set("host", c.Host)
+		query.Set("port", strconv.Itoa(int(c.Port)))
+	}
+	return query
+}
+
+// yugabyteQuery returns the parameters of the YugabyteDB smart driver DSNs and URLs: the PostgreSQL parameters and the
+// load_balance and topology_keys parameters
+func (c Config) yugabyteQuery() url.Values {
+	query := c.postgresQuery()
+	if c.Yugabyte != nil {
+		if c.Yugabyte.LoadBalance {
+			query.Set("load_balance", "true")
+		}
+		setQuery(query, "topology_keys", c.Yugabyte.TopologyKeys)
+	}
+	return query
+}
+
+// YugabyteDSN returns the configuration as a postgres:// connection URL accepted by the YugabyteDB smart drivers, with
+// the load_balance and topology_keys parameters that libpq, pgx and lib/pq reject
+func (c Config) YugabyteDSN() string {
+	return c.databaseURL("postgres", c.yugabyteQuery())
+}
+
+// parseYugabyteQuery reads the load_balance and topology_keys parameters of a YugabyteDB URL
+func parseYugabyteQuery(config Config, query url.Values) (Config, error) {
+	options := YugabyteConfig{TopologyKeys: query.Get("topology_keys")}
+	if strLoadBalance := query.Get("load_balance"); strLoadBalance != "" {
+		loadBalance, err := strconv.ParseBool(strLoadBalance)
+		if err != nil {
+			return Config{}, errorex.New(
+				ErrorCodeURLParseError,
+				urlParseError,
+				fmt.Sprintf("\"%s\" value for load_balance is invalid", strLoadBalance),
+			)
+		}
+		options.LoadBalance = loadBalance
+	}
+	if options != (YugabyteConfig{}) {
+		config.Yugabyte = &options
+	}
+	return config, nil
+}